@@ -0,0 +1,141 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+const (
+	// columnFamilySizeMetric ranks the column families when some of them have to be dropped, the largest are kept.
+	columnFamilySizeMetric = "db.liveDiskSpaceUsedBytes"
+
+	// cardinalityDataPoints are the numeric cardinality metrics reported when the limit is exceeded, they are
+	// reserved in the limit.
+	cardinalityDataPoints = 3
+)
+
+// limitDataPoints keeps the data points reported by the entity within maxDataPoints. When the limit is exceeded,
+// the percentiles of the column family samples are dropped first, starting with the smallest tables, then the
+// column family samples of the smallest tables. The dropped data points are reported in the CassandraSample ms.
+// A limit of 0 disables it.
+func limitDataPoints(e *integration.Entity, ms *metric.Set, definitions Definitions, maxDataPoints int) {
+	if maxDataPoints <= 0 {
+		return
+	}
+
+	total := 0
+	var columnFamilies []*metric.Set
+	for _, s := range e.Metrics {
+		total += dataPoints(s)
+		if s.Metrics["event_type"] == columnFamilySample {
+			columnFamilies = append(columnFamilies, s)
+		}
+	}
+	if total <= maxDataPoints {
+		return
+	}
+	collected := total
+	limit := maxDataPoints - cardinalityDataPoints
+
+	sort.SliceStable(columnFamilies, func(a, b int) bool {
+		return columnFamilySize(columnFamilies[a]) < columnFamilySize(columnFamilies[b])
+	})
+
+	droppedPercentiles := 0
+	percentiles := percentileAliases(definitions.ColumnFamilyMetrics)
+	for _, s := range columnFamilies {
+		if total <= limit {
+			break
+		}
+		for _, alias := range percentiles {
+			if _, found := s.Metrics[alias]; found {
+				delete(s.Metrics, alias)
+				droppedPercentiles++
+				total--
+			}
+		}
+	}
+
+	dropped := make(map[*metric.Set]struct{})
+	for total > limit && len(columnFamilies) > 0 {
+		smallest := columnFamilies[0]
+		columnFamilies = columnFamilies[1:]
+
+		total -= dataPoints(smallest)
+		dropped[smallest] = struct{}{}
+	}
+
+	if len(dropped) > 0 {
+		var kept []*metric.Set
+		for _, s := range e.Metrics {
+			if _, found := dropped[s]; !found {
+				kept = append(kept, s)
+			}
+		}
+		e.Metrics = kept
+	}
+
+	var droppedNames []string
+	if droppedPercentiles > 0 {
+		droppedNames = append(droppedNames, "percentiles")
+	}
+	if len(dropped) > 0 {
+		droppedNames = append(droppedNames, "columnFamilies")
+	}
+
+	log.Warn("Collected %d data points, over the limit of %d. Dropped %d column family percentiles and %d column family samples",
+		collected, maxDataPoints, droppedPercentiles, len(dropped))
+	if total > limit {
+		log.Warn("Reporting %d data points, the limit of %d cannot be met by dropping column family metrics", total+cardinalityDataPoints, maxDataPoints)
+	}
+
+	setCardinalityMetric(ms, "cardinality.collectedDataPoints", collected, metric.GAUGE)
+	setCardinalityMetric(ms, "cardinality.droppedPercentiles", droppedPercentiles, metric.GAUGE)
+	setCardinalityMetric(ms, "cardinality.droppedColumnFamilies", len(dropped), metric.GAUGE)
+	setCardinalityMetric(ms, "cardinality.dropped", strings.Join(droppedNames, ","), metric.ATTRIBUTE)
+}
+
+func setCardinalityMetric(ms *metric.Set, name string, value interface{}, metricType metric.SourceType) {
+	if err := ms.SetMetric(name, value, metricType); err != nil {
+		log.Debug("Failed to set metric value: %v", err)
+	}
+}
+
+// dataPoints returns the number of numeric metrics of the set, attributes are not counted.
+func dataPoints(s *metric.Set) int {
+	result := 0
+	for _, value := range s.Metrics {
+		if _, isString := value.(string); !isString {
+			result++
+		}
+	}
+	return result
+}
+
+// percentileAliases returns the names of the metrics reading a percentile attribute.
+func percentileAliases(queries []Query) []string {
+	var result []string
+	for _, query := range queries {
+		for _, attr := range query.Attributes {
+			if strings.HasSuffix(attr.MBeanAttribute, "Percentile") || attr.MBeanAttribute == recentValuesAttribute ||
+				(attr.Unit == unitPartitionSizeBuckets && percentileAliasRegex.MatchString(attr.Alias)) {
+				result = append(result, attr.Alias)
+			}
+		}
+	}
+	return result
+}
+
+func columnFamilySize(s *metric.Set) float64 {
+	size, _ := s.Metrics[columnFamilySizeMetric].(float64)
+	return size
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectedDataPoints returns the number of data points reported without limit.
+func collectedDataPoints(t *testing.T) int {
+	t.Helper()
+
+	i := setupCollection(t)
+	require.NoError(t, collectMetrics(i, newFakeJMXClient(t, "cassandra.yml"), NewDefinitions()))
+	return entityDataPoints(i)
+}
+
+func entityDataPoints(i *integration.Integration) int {
+	result := 0
+	for _, ms := range i.LocalEntity().Metrics {
+		result += dataPoints(ms)
+	}
+	return result
+}
+
+func columnFamilies(samples []map[string]interface{}) []string {
+	var result []string
+	for _, sample := range samples {
+		result = append(result, sample["db.keyspaceAndColumnFamily"].(string))
+	}
+	return result
+}
+
+func TestLimitDataPoints_WithinLimit(t *testing.T) {
+	total := collectedDataPoints(t)
+
+	i := setupCollection(t)
+	args.MaxDataPoints = total
+	require.NoError(t, collectMetrics(i, newFakeJMXClient(t, "cassandra.yml"), NewDefinitions()))
+
+	assert.Equal(t, total, entityDataPoints(i))
+	assert.NotContains(t, samplesByEventType(i)["CassandraSample"][0], "cardinality.dropped")
+}
+
+func TestLimitDataPoints_DropsPercentiles(t *testing.T) {
+	client := newFakeJMXClient(t, "cassandra.yml")
+	client.mBeans["org.apache.cassandra.metrics:type=Table,keyspace=orders,scope=items,name=ReadLatency"] = map[string]interface{}{
+		"50thPercentile": 500.0, "75thPercentile": 600.0, "95thPercentile": 700.0,
+		"98thPercentile": 800.0, "99thPercentile": 900.0, "999thPercentile": 1000.0,
+	}
+	i := setupCollection(t)
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+	total := entityDataPoints(i)
+
+	i = setupCollection(t)
+	// The six percentiles of orders.items, the smallest table with percentiles, make room for the cardinality metrics.
+	args.MaxDataPoints = total - 1
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+
+	samples := samplesByEventType(i)
+	assert.Equal(t, []string{"orders.items", "users.profiles", "users.sessions"}, columnFamilies(samples["CassandraColumnFamilySample"]))
+	for _, sample := range samples["CassandraColumnFamilySample"] {
+		if sample["db.keyspaceAndColumnFamily"] == "orders.items" {
+			assert.NotContains(t, sample, "query.readLatency99thPercentileMilliseconds")
+		} else if sample["db.keyspaceAndColumnFamily"] == "users.profiles" {
+			assert.Contains(t, sample, "query.readLatency99thPercentileMilliseconds", "the largest table keeps its percentiles")
+		}
+	}
+	// Percentiles of other samples are kept.
+	assert.Contains(t, samples["CassandraSample"][0], "query.readLatency99thPercentileMilliseconds")
+
+	sample := samples["CassandraSample"][0]
+	assert.Equal(t, "percentiles", sample["cardinality.dropped"])
+	assert.Equal(t, float64(total), sample["cardinality.collectedDataPoints"])
+	assert.Equal(t, float64(6), sample["cardinality.droppedPercentiles"])
+	assert.Equal(t, float64(0), sample["cardinality.droppedColumnFamilies"])
+	assert.LessOrEqual(t, entityDataPoints(i), args.MaxDataPoints)
+}
+
+func TestLimitDataPoints_DropsSmallestTables(t *testing.T) {
+	total := collectedDataPoints(t)
+
+	i := setupCollection(t)
+	// Dropping the two percentiles and the users.sessions sample, with its LiveSSTableCount, doesn't leave room for
+	// the cardinality metrics, so the orders.items sample is dropped too.
+	args.MaxDataPoints = total - 1
+	require.NoError(t, collectMetrics(i, newFakeJMXClient(t, "cassandra.yml"), NewDefinitions()))
+
+	samples := samplesByEventType(i)
+	assert.Equal(t, []string{"users.profiles"}, columnFamilies(samples["CassandraColumnFamilySample"]))
+
+	sample := samples["CassandraSample"][0]
+	assert.Equal(t, "percentiles,columnFamilies", sample["cardinality.dropped"])
+	assert.Equal(t, float64(2), sample["cardinality.droppedColumnFamilies"])
+	assert.LessOrEqual(t, entityDataPoints(i), args.MaxDataPoints)
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -21,24 +23,68 @@ import (
 type argumentList struct {
 	sdkArgs.DefaultArgumentList
 
-	Hostname            string `default:"localhost" help:"Hostname or IP where Cassandra is running."`
-	Port                int    `default:"7199" help:"Port on which JMX server is listening."`
-	Username            string `default:"" help:"Username for accessing JMX."`
-	Password            string `default:"" help:"Password for the given user."`
-	ConfigPath          string `default:"/etc/cassandra/cassandra.yaml" help:"Cassandra configuration file."`
-	Timeout             int    `default:"2000" help:"Timeout in milliseconds per single JMX query."`
-	ColumnFamiliesLimit int    `default:"20" help:"Limit on number of Cassandra Column Families."`
-	RemoteMonitoring    bool   `default:"false" help:"Identifies the monitored entity as 'remote'. In doubt: set to true."`
-	KeyStore            string `default:"" help:"The location for the keystore containing JMX Client's SSL certificate"`
-	KeyStorePassword    string `default:"" help:"Password for the SSL Key Store"`
-	TrustStore          string `default:"" help:"The location for the keystore containing JMX Server's SSL certificate"`
-	TrustStorePassword  string `default:"" help:"Password for the SSL Trust Store"`
-	ShowVersion         bool   `default:"false" help:"Print build information and exit"`
-	LongRunning         bool   `default:"false" help:"BETA: In long-running mode integration process will be kept alive"`
-	HeartbeatInterval   int    `default:"5" help:"BETA: Interval in seconds for submitting the heartbeat while in long-running mode"`
-	Interval            int    `default:"30" help:"BETA: Interval in seconds for collecting data while while in long-running mode"`
-	MetricsFilter       string `default:"" help:"BETA: Filtering rules for metrics collection"`
-	EnableInternalStats bool   `default:"false" help:"Print nrjmx internal query stats for troubleshooting"`
+	Hostname                string `default:"localhost" help:"Hostname or IP where Cassandra is running."`
+	Port                    int    `default:"7199" help:"Port on which JMX server is listening."`
+	Username                string `default:"" help:"Username for accessing JMX."`
+	Password                string `default:"" help:"Password for the given user."`
+	ConfigPath              string `default:"/etc/cassandra/cassandra.yaml" help:"Cassandra configuration file."`
+	InventoryRedactPatterns string `default:"" help:"YAML list of regular expressions matching the dotted path of the inventory settings to omit, besides the default ones covering passwords, secrets, keys, keystores and tokens"`
+	InventoryMaxValueLength int    `default:"1024" help:"Maximum length of the inventory values, longer values are truncated. 0 means no limit."`
+	RuntimeInventory        bool   `default:"false" help:"Report the effective settings of the node read over JMX as 'runtime.' inventory items"`
+	SchemaInventory         bool   `default:"false" help:"Report the replication of the keyspaces and the options of the tables read over the native protocol as 'schema.' inventory items"`
+	SchemaTablesLimit       int    `default:"200" help:"Limit on number of tables reported as 'schema.<keyspace>.<table>' inventory items, in name order"`
+	SystemViews             bool   `default:"false" help:"Read the system_views virtual tables of Cassandra 4.0 and later over the native protocol"`
+	Timeout                 int    `default:"2000" help:"Timeout in milliseconds per single JMX query."`
+	ColumnFamiliesLimit     int    `default:"20" help:"Limit on number of Cassandra Column Families."`
+	RemoteMonitoring        bool   `default:"false" help:"Identifies the monitored entity as 'remote'. In doubt: set to true."`
+	KeyStore                string `default:"" help:"The location for the keystore containing JMX Client's SSL certificate"`
+	KeyStorePassword        string `default:"" help:"Password for the SSL Key Store"`
+	TrustStore              string `default:"" help:"The location for the keystore containing JMX Server's SSL certificate"`
+	TrustStorePassword      string `default:"" help:"Password for the SSL Trust Store"`
+	ShowVersion             bool   `default:"false" help:"Print build information and exit"`
+	LongRunning             bool   `default:"false" help:"BETA: In long-running mode integration process will be kept alive"`
+	HeartbeatInterval       int    `default:"5" help:"BETA: Interval in seconds for submitting the heartbeat while in long-running mode"`
+	Interval                int    `default:"30" help:"BETA: Interval in seconds for collecting data while while in long-running mode"`
+	IntervalMultipliers     string `default:"" help:"BETA: YAML map of definition groups or MBeans to the number of intervals between their collections in long-running mode"`
+	StaleMetrics            string `default:"cache" help:"BETA: Metrics not collected in an interval of the long-running mode are reported from the 'cache' of the last collection, or 'skip'ped"`
+	MetricsFilter           string `default:"" help:"BETA: Filtering rules for metrics collection"`
+	MetricsFilterStrict     bool   `default:"false" help:"BETA: Fail when a metrics filtering rule matches no known metric, instead of logging a warning"`
+	EnableInternalStats     bool   `default:"false" help:"Print nrjmx internal query stats for troubleshooting"`
+	ThreadPoolMetrics       bool   `default:"false" help:"Report a CassandraThreadPoolSample for each thread pool discovered on the node"`
+	HistogramMode           string `default:"percentiles" help:"Report the histograms as 'percentiles' gauges, or as 'summary' gauges of their count, min, max and mean, and the percentiles of the RecentValues buckets with the jolokia transport"`
+	LegacyHistogramUnits    bool   `default:"true" help:"Report the SSTablesPerRead and TombstoneScanned histogram percentiles converted from microseconds, like previous versions, and the db.SSTablesPerRead<N>thPercentileMilliseconds metrics. Set to false to report them as counts"`
+	LargePartitionThreshold int    `default:"100" help:"Size in MiB over which the partitions are counted in db.partitionsOverWarningThreshold, the compaction_large_partition_warning_threshold_mb of the node. Only reported with the jolokia transport"`
+	MaxDataPoints           int    `default:"0" help:"Maximum number of data points reported per collection, column family percentiles and then the smallest tables are dropped over it. 0 means no limit"`
+	DroppedMessageMetrics   bool   `default:"false" help:"Report a CassandraDroppedMessageSample for each dropped message verb discovered on the node"`
+	HintsMetrics            bool   `default:"false" help:"Report a CassandraHintsSample for each endpoint hints are stored for, with the pending hints files when SYSTEM_VIEWS is enabled, Cassandra 4.1 and later, and their size since 5.0"`
+	ClientMetrics           bool   `default:"false" help:"Report a CassandraClientSample for each user, driver and protocol version connected to the node. Only with the cql transport"`
+	SstableTaskMetrics      bool   `default:"false" help:"Report a CassandraSSTableTaskSample for each running compaction, cleanup or other SSTable task. Only with the cql transport"`
+	LogEvents               bool   `default:"false" help:"Report the GC pauses, dropped messages, tombstone thresholds, large partitions, hinted handoff problems and gossip transitions found in the system log as events. Long-running mode only"`
+	SystemLogPath           string `default:"/var/log/cassandra/system.log" help:"Cassandra system log followed for the log events"`
+	GCPauseThreshold        int    `default:"1000" help:"Minimum duration in milliseconds of the GC pauses reported as log events"`
+	NodeStatus              bool   `default:"false" help:"Report a CassandraNodeStatusSample with the reachability of JMX and the native transport, the operation mode of the node and an OK, WARNING or CRITICAL status"`
+	Transport               string `default:"jmx" help:"Transport used to query the MBeans: 'jmx' (nrjmx) or 'jolokia', or 'cql' to collect the metrics from the system_views virtual tables instead"`
+	JolokiaURL              string `default:"http://localhost:8778/jolokia/" help:"URL of the Jolokia agent when using the jolokia transport"`
+	JolokiaUsername         string `default:"" help:"Username for accessing the Jolokia agent"`
+	JolokiaPassword         string `default:"" help:"Password for the given Jolokia user"`
+	JolokiaCACert           string `default:"" help:"CA certificate file used to verify the Jolokia agent certificate"`
+	JolokiaClientCert       string `default:"" help:"Client certificate file used to authenticate against the Jolokia agent"`
+	JolokiaClientKey        string `default:"" help:"Client key file used to authenticate against the Jolokia agent"`
+	JolokiaTLSSkipVerify    bool   `default:"false" help:"Skip the verification of the Jolokia agent certificate"`
+	CQLHostname             string `default:"" help:"Hostname or IP of the Cassandra native transport, defaults to the hostname"`
+	CQLPort                 int    `default:"9042" help:"Port of the Cassandra native transport, used to read the system_views virtual tables"`
+	CQLUsername             string `default:"" help:"Username for accessing the native transport"`
+	CQLPassword             string `default:"" help:"Password for the given native transport user"`
+	CQLTLS                  bool   `default:"false" help:"Connect to the native transport using TLS"`
+	CQLCACert               string `default:"" help:"CA certificate file used to verify the native transport certificate"`
+	CQLTLSSkipVerify        bool   `default:"false" help:"Skip the verification of the native transport certificate"`
+	Record                  string `default:"" help:"Troubleshooting: save all the MBean queries and responses to the given file"`
+	RecordScrub             bool   `default:"false" help:"Troubleshooting: remove passwords and hostnames from the recorded MBean responses"`
+	RecordCycles            int    `default:"1" help:"Troubleshooting: number of collections recorded in long-running mode, the next ones are not recorded"`
+	Replay                  string `default:"" help:"Troubleshooting: collect the metrics from a recording file instead of connecting to the endpoint"`
+	Explain                 bool   `default:"false" help:"Troubleshooting: print the metrics that would be collected from the endpoint and why the rest are excluded, then exit"`
+	Discover                bool   `default:"false" help:"Print the Cassandra and JVM MBean attributes found on the endpoint and whether they are collected, then exit"`
+	DiscoverFormat          string `default:"table" help:"Output of the discover mode: 'table', or 'yaml' for a definitions skeleton of the attributes not collected yet"`
 }
 
 const (
@@ -52,7 +98,11 @@ var (
 	gitCommit          = ""
 	buildDate          = ""
 
-	errNRJMXNotRunning = errors.New("nrjmx client sub-process not running")
+	// intervalUnit is the unit of INTERVAL and HEARTBEAT_INTERVAL in long-running mode.
+	intervalUnit = time.Second
+
+	errNRJMXNotRunning      = errors.New("nrjmx client sub-process not running")
+	errInvalidMetricsFilter = errors.New("metrics filtering rules match no known metric")
 )
 
 func main() {
@@ -71,8 +121,23 @@ func main() {
 		os.Exit(0)
 	}
 
-	if args.HasMetrics() {
-		jmxClient, conErr := openJMXConnection()
+	if args.Explain {
+		fatalIfErr(runExplain(os.Stdout))
+		os.Exit(0)
+	}
+
+	if args.Discover {
+		fatalIfErr(runDiscover(os.Stdout))
+		os.Exit(0)
+	}
+
+	var jmxClient MBeanSource
+	if args.Transport != transportCQL && (args.HasMetrics() || (args.HasInventory() && args.RuntimeInventory)) {
+		var conErr error
+		jmxClient, conErr = openMBeanSource()
+		if conErr != nil {
+			publishNodeStatus(i, nil)
+		}
 		fatalIfErr(conErr)
 
 		defer func() {
@@ -81,18 +146,32 @@ func main() {
 					"Failed to close JMX connection: %s", err)
 			}
 		}()
+	}
 
+	defer func() {
+		if err := pendingHintsSource.Close(); err != nil {
+			log.Error("Failed to close CQL connection: %s", err)
+		}
+	}()
+
+	if args.HasMetrics() && args.Transport == transportCQL {
+		err := runSystemViewsCollection(i)
+		if err != nil {
+			publishNodeStatus(i, nil)
+		}
+		fatalIfErr(err)
+	} else if args.HasMetrics() {
 		err := runMetricCollection(i, jmxClient)
+		flushRecording(jmxClient)
+		if err != nil {
+			publishNodeStatus(i, jmxClient)
+		}
 		fatalIfErr(err)
 	}
 
 	if args.HasInventory() {
-		e, err := entity(i)
-		fatalIfErr(err)
-
-		rawInventory, err := getInventory()
-		fatalIfErr(err)
-		err = populateInventory(e.Inventory, rawInventory)
+		err := runInventoryCollection(i, jmxClient)
+		flushRecording(jmxClient)
 		fatalIfErr(err)
 	}
 
@@ -100,26 +179,127 @@ func main() {
 }
 
 // runMetricCollection will perform the metrics collection.
-func runMetricCollection(i *integration.Integration, jmxClient *gojmx.Client) error {
-	definitions := NewDefinitions()
+func runMetricCollection(i *integration.Integration, jmxClient MBeanSource) error {
+	definitions, err := collectionDefinitions()
+	if err != nil {
+		return err
+	}
 
-	config, err := LoadFilteringConfig(args.MetricsFilter)
+	config, err := loadMetricsFilter(definitions)
 	if err != nil {
-		return fmt.Errorf("failed to load metrics filtering configuration, error: %w", err)
+		return err
 	}
 	definitions.Filter(config)
 
+	multipliers, err := loadIntervalMultipliers(args.IntervalMultipliers)
+	if err != nil {
+		return fmt.Errorf("failed to load interval multipliers, error: %w", err)
+	}
+	if err := definitions.SetIntervalMultipliers(multipliers); err != nil {
+		return fmt.Errorf("invalid interval multipliers, error: %w", err)
+	}
+
 	if args.LongRunning {
 		return collectMetricsEachInterval(i, jmxClient, definitions)
 	}
 	return collectMetrics(i, jmxClient, definitions)
 }
 
+// runInventoryCollection reports the settings read from the configuration files and, when enabled, the effective
+// settings of the node. When the runtime inventory is enabled the configuration file is optional, as it is not
+// available when monitoring remote nodes.
+func runInventoryCollection(i *integration.Integration, jmxClient MBeanSource) error {
+	e, err := entity(i)
+	if err != nil {
+		return err
+	}
+
+	redact, err := loadRedactPatterns(args.InventoryRedactPatterns)
+	if err != nil {
+		return fmt.Errorf("failed to load inventory redact patterns, error: %w", err)
+	}
+
+	rawInventory, err := getInventory()
+	if err == nil {
+		err = populateInventory(e.Inventory, rawInventory, redact)
+	}
+	if err != nil {
+		if !args.RuntimeInventory {
+			return err
+		}
+		log.Warn("Failed to read the inventory from %s, its settings are not reported: %v", args.ConfigPath, err)
+	}
+
+	populateConfInventory(e.Inventory, getConfInventory(filepath.Dir(args.ConfigPath)), redact)
+
+	var cqlClient CQLSource
+	if (args.RuntimeInventory && args.SystemViews) || args.SchemaInventory {
+		cqlClient, err = openCQLSource()
+		if err != nil {
+			log.Warn("Failed to connect to the native transport, the inventory read over CQL is not reported: %v", err)
+		} else {
+			defer cqlClient.Close()
+		}
+	}
+
+	if args.RuntimeInventory {
+		var settingsClient CQLSource
+		if args.SystemViews {
+			settingsClient = cqlClient
+		}
+
+		settings, err := getRuntimeSettings(jmxClient, settingsClient)
+		if err != nil {
+			return err
+		}
+		populateRuntimeInventory(e.Inventory, settings, redact)
+	}
+
+	if args.SchemaInventory && cqlClient != nil {
+		schema, err := getSchemaInventory(cqlClient, args.SchemaTablesLimit)
+		if err != nil {
+			log.Warn("Failed to read the schema inventory: %v", err)
+			return nil
+		}
+		populateSchemaInventory(e.Inventory, schema, redact)
+	}
+	return nil
+}
+
+// loadMetricsFilter loads the metrics filtering configuration and warns about the rules matching no metric of the
+// definitions. In strict mode those rules are reported as an error.
+func loadMetricsFilter(definitions Definitions) (FilteringConfig, error) {
+	config, err := LoadFilteringConfig(args.MetricsFilter)
+	if err != nil {
+		return config, fmt.Errorf("failed to load metrics filtering configuration, error: %w", err)
+	}
+
+	warnings := config.Validate(definitions)
+	for _, warning := range warnings {
+		log.Warn("%s", warning)
+	}
+
+	if args.MetricsFilterStrict && len(warnings) > 0 {
+		return config, fmt.Errorf("%w: %s", errInvalidMetricsFilter, strings.Join(warnings, "; "))
+	}
+	return config, nil
+}
+
 // collectMetricsEachInterval will collect the metrics periodically when configured in long-running mode.
-func collectMetricsEachInterval(i *integration.Integration, jmxClient *gojmx.Client, definitions Definitions) error {
-	metricInterval := time.NewTicker(time.Duration(args.Interval) * time.Second)
+func collectMetricsEachInterval(i *integration.Integration, jmxClient MBeanSource, definitions Definitions) error {
+	scheduler, err := newIntervalScheduler(args.StaleMetrics)
+	if err != nil {
+		return err
+	}
 
-	runHeartBeat()
+	metricInterval := time.NewTicker(time.Duration(args.Interval) * intervalUnit)
+	defer metricInterval.Stop()
+
+	logEvents := openLogEvents()
+	defer logEvents.Close()
+
+	stopHeartBeat := runHeartBeat()
+	defer stopHeartBeat()
 
 	// do ... while.
 	for ; true; <-metricInterval.C {
@@ -128,10 +308,21 @@ func collectMetricsEachInterval(i *integration.Integration, jmxClient *gojmx.Cli
 			return errNRJMXNotRunning
 		}
 
-		if err := collectMetrics(i, jmxClient, definitions); err != nil {
+		logEvents.collect(i)
+
+		err := collectScheduledMetrics(i, jmxClient, definitions, scheduler)
+		endRecordingCycle(jmxClient)
+		if err != nil {
+			scheduler.discard()
 			log.Error("Failed to collect metrics, error: %v", err)
+			if args.NodeStatus || logEvents != nil {
+				if err := publishFailedCollection(i); err != nil {
+					log.Error("Failed to publish node status and log events, error: %v", err)
+				}
+			}
 			continue
 		}
+		scheduler.next()
 
 		if err := i.Publish(); err != nil {
 			log.Error("Failed to publish metrics, error: %v", err)
@@ -143,7 +334,13 @@ func collectMetricsEachInterval(i *integration.Integration, jmxClient *gojmx.Cli
 }
 
 // collectMetrics will gather all the required metrics from the JMX endpoint and attach them the the sdk integration.
-func collectMetrics(i *integration.Integration, jmxClient *gojmx.Client, definitions Definitions) error {
+func collectMetrics(i *integration.Integration, jmxClient MBeanSource, definitions Definitions) error {
+	return collectScheduledMetrics(i, jmxClient, definitions, nil)
+}
+
+// collectScheduledMetrics collects the metrics of the queries due in the current interval of the scheduler.
+// A nil scheduler collects all of them.
+func collectScheduledMetrics(i *integration.Integration, jmxClient MBeanSource, definitions Definitions, scheduler *intervalScheduler) error {
 	// For troubleshooting purpose, if enabled, integration will log internal query stats.
 	if args.EnableInternalStats {
 		defer func() {
@@ -156,36 +353,158 @@ func collectMetrics(i *integration.Integration, jmxClient *gojmx.Client, definit
 		return fmt.Errorf("failed to create entity: %w", err)
 	}
 
-	rawMetrics, err := getMetrics(jmxClient, definitions.Metrics)
+	if args.NodeStatus {
+		collectNodeStatus(e, jmxClient)
+	}
+
+	due := scheduler.dueDefinitions(definitions)
+
+	rawMetrics, err := getMetrics(jmxClient, due.Metrics)
 	if err != nil {
 		return err
 	}
 
-	commonMetrics, err := getMetrics(jmxClient, definitions.Common)
+	commonMetrics, err := getMetrics(jmxClient, due.Common)
 	if err != nil {
 		return err
 	}
 
-	ms := metricSet(e, "CassandraSample", args.Hostname, args.Port, args.RemoteMonitoring)
-	populateMetrics(ms, rawMetrics, definitions.Metrics)
-	populateMetrics(ms, commonMetrics, definitions.Common)
+	ms := metricSet(e, cassandraSample, args.Hostname, args.Port, args.RemoteMonitoring)
+	populateMetrics(ms, rawMetrics, due.Metrics)
+	populateMetrics(ms, commonMetrics, due.Common)
+	scheduler.restore(ms, sampleKey(cassandraSample, ""), definitions.Metrics, definitions.Common)
 
 	if args.ColumnFamiliesLimit > 0 {
-		allColumnFamilies, err := getColumnFamilyMetrics(jmxClient, definitions.ColumnFamilyMetrics)
-		if err != nil {
-			return err
+		if len(due.ColumnFamilyMetrics) == 0 {
+			scheduler.replay(e, columnFamilySample)
+		} else {
+			allColumnFamilies, err := getColumnFamilyMetrics(jmxClient, due.ColumnFamilyMetrics, definitions.columnFamilyFilter)
+			if err != nil {
+				return err
+			}
+
+			for _, key := range sortedKeys(allColumnFamilies) {
+				columnFamilyMetrics := allColumnFamilies[key]
+				s := metricSet(e, columnFamilySample, args.Hostname, args.Port, args.RemoteMonitoring)
+				populateMetrics(s, commonMetrics, due.Common)
+				populateMetrics(s, columnFamilyMetrics, due.ColumnFamilyMetrics)
+				populatePartitionSizes(s, columnFamilyMetrics, due.ColumnFamilyMetrics, args.LargePartitionThreshold)
+				populateAttributes(s, columnFamilyMetrics, columnFamiliesSampleAttributes)
+				scheduler.restore(s, sampleKey(columnFamilySample, key), definitions.Common, definitions.ColumnFamilyMetrics)
+			}
+		}
+	}
+
+	if args.ThreadPoolMetrics {
+		if len(due.ThreadPoolMetrics) == 0 {
+			scheduler.replay(e, threadPoolSample)
+		} else {
+			allThreadPools, err := getScopedMetrics(jmxClient, due.ThreadPoolMetrics, threadPoolRegex, "path", "pool")
+			if err != nil {
+				return err
+			}
+
+			for _, key := range sortedKeys(allThreadPools) {
+				threadPoolMetrics := allThreadPools[key]
+				s := metricSet(e, threadPoolSample, args.Hostname, args.Port, args.RemoteMonitoring)
+				populateMetrics(s, commonMetrics, due.Common)
+				populateMetrics(s, threadPoolMetrics, due.ThreadPoolMetrics)
+				populateAttributes(s, threadPoolMetrics, threadPoolSampleAttributes)
+				scheduler.restore(s, sampleKey(threadPoolSample, key), definitions.Common, definitions.ThreadPoolMetrics)
+			}
+		}
+	}
+
+	if args.DroppedMessageMetrics {
+		if len(due.DroppedMessageMetrics) == 0 {
+			scheduler.replay(e, droppedMessageSample)
+		} else {
+			allDroppedMessages, err := getScopedMetrics(jmxClient, due.DroppedMessageMetrics, droppedMessageRegex, "verb")
+			if err != nil {
+				return err
+			}
+
+			for _, key := range sortedKeys(allDroppedMessages) {
+				droppedMessageMetrics := allDroppedMessages[key]
+				s := metricSet(e, droppedMessageSample, args.Hostname, args.Port, args.RemoteMonitoring)
+				populateMetrics(s, commonMetrics, due.Common)
+				populateMetrics(s, droppedMessageMetrics, due.DroppedMessageMetrics)
+				populateAttributes(s, droppedMessageMetrics, droppedMessageSampleAttributes)
+				scheduler.restore(s, sampleKey(droppedMessageSample, key), definitions.Common, definitions.DroppedMessageMetrics)
+			}
 		}
+	}
+
+	if args.HintsMetrics {
+		if len(due.HintsMetrics) == 0 {
+			scheduler.replay(e, hintsSample)
+		} else {
+			allHints, err := getHintsMetrics(jmxClient, due.HintsMetrics)
+			if err != nil {
+				return err
+			}
 
-		for _, columnFamilyMetrics := range allColumnFamilies {
-			s := metricSet(e, "CassandraColumnFamilySample", args.Hostname, args.Port, args.RemoteMonitoring)
-			populateMetrics(s, commonMetrics, definitions.Common)
-			populateMetrics(s, columnFamilyMetrics, definitions.ColumnFamilyMetrics)
-			populateAttributes(s, columnFamilyMetrics, columnFamiliesSampleAttributes)
+			for _, key := range sortedKeys(allHints) {
+				hintsMetrics := allHints[key]
+				s := metricSet(e, hintsSample, args.Hostname, args.Port, args.RemoteMonitoring)
+				populateMetrics(s, commonMetrics, due.Common)
+				populateMetrics(s, hintsMetrics, due.HintsMetrics)
+				populateAttributes(s, hintsMetrics, hintsSampleAttributes)
+				scheduler.restore(s, sampleKey(hintsSample, key), definitions.Common, definitions.HintsMetrics)
+			}
 		}
 	}
+
+	limitDataPoints(e, ms, definitions, args.MaxDataPoints)
 	return nil
 }
 
+// publishFailedCollection publishes the CassandraNodeStatusSample and the log events when the collection of the
+// metrics fails, dropping the metrics collected so far, so an unhealthy node is still reported.
+func publishFailedCollection(i *integration.Integration) error {
+	e, err := entity(i)
+	if err != nil {
+		return fmt.Errorf("failed to create entity: %w", err)
+	}
+
+	var statusSets []*metric.Set
+	for _, ms := range e.Metrics {
+		if ms.Metrics["event_type"] == nodeStatusSample {
+			statusSets = append(statusSets, ms)
+		}
+	}
+	e.Metrics = statusSets
+	return i.Publish()
+}
+
+// publishNodeStatus publishes the CassandraNodeStatusSample, when enabled, before the integration exits on a failed
+// connection or collection, so an unreachable node is still reported. The node is checked when the collection failed
+// before reporting it, and JMX is reported as not reachable when no client could be opened.
+func publishNodeStatus(i *integration.Integration, jmxClient MBeanSource) {
+	if !args.NodeStatus {
+		return
+	}
+
+	e, err := entity(i)
+	if err != nil {
+		log.Error("Failed to create entity: %v", err)
+		return
+	}
+
+	reported := slices.ContainsFunc(e.Metrics, func(ms *metric.Set) bool { return ms.Metrics["event_type"] == nodeStatusSample })
+	if !reported {
+		if jmxClient == nil && args.Transport != transportCQL {
+			collectUnreachableNodeStatus(e)
+		} else {
+			collectNodeStatus(e, jmxClient)
+		}
+	}
+
+	if err := publishFailedCollection(i); err != nil {
+		log.Error("Failed to publish node status, error: %v", err)
+	}
+}
+
 func metricSet(e *integration.Entity, eventType, hostname string, port int, remoteMonitoring bool) *metric.Set {
 	if remoteMonitoring {
 		return e.NewMetricSet(
@@ -223,8 +542,50 @@ func getJMXConfig() *gojmx.JMXConfig {
 	return jmxConfig
 }
 
+// openMBeanSource opens the connection with the endpoint using the configured transport. When configured, the
+// MBeans are served from a recording, or the queries performed to the endpoint are recorded.
+func openMBeanSource() (MBeanSource, error) {
+	if args.Replay != "" {
+		return newReplaySource(args.Replay)
+	}
+
+	source, err := openTransport()
+	if err != nil || args.Record == "" {
+		return source, err
+	}
+	return newRecordingSource(source, args.Record, args.RecordScrub, args.RecordCycles), nil
+}
+
+// openTransport opens the connection with the endpoint using the configured transport.
+func openTransport() (MBeanSource, error) {
+	switch args.Transport {
+	case transportJMX:
+		return openJMXConnection()
+	case transportJolokia:
+		return newJolokiaClient(getJolokiaConfig())
+	case transportCQL:
+		return nil, fmt.Errorf("the %q transport reads the system_views virtual tables, it doesn't serve MBeans", transportCQL)
+	default:
+		return nil, fmt.Errorf("unsupported transport: %q, valid values are: %q, %q, %q", args.Transport, transportJMX, transportJolokia, transportCQL)
+	}
+}
+
+// getJolokiaConfig will use the integration args to prepare the configuration for the Jolokia client.
+func getJolokiaConfig() jolokiaConfig {
+	return jolokiaConfig{
+		URL:                args.JolokiaURL,
+		Username:           args.JolokiaUsername,
+		Password:           args.JolokiaPassword,
+		Timeout:            time.Duration(args.Timeout) * time.Millisecond,
+		CACert:             args.JolokiaCACert,
+		ClientCert:         args.JolokiaClientCert,
+		ClientKey:          args.JolokiaClientKey,
+		InsecureSkipVerify: args.JolokiaTLSSkipVerify,
+	}
+}
+
 // openJMXConnection configures the JMX client and attempts to connect to the endpoint.
-func openJMXConnection() (*gojmx.Client, error) {
+func openJMXConnection() (MBeanSource, error) {
 	jmxConfig := getJMXConfig()
 
 	hideSecrets := true
@@ -261,24 +622,42 @@ func entity(i *integration.Integration) (*integration.Entity, error) {
 	return i.LocalEntity(), nil
 }
 
-// runHeartBeat is used in long-running mode to signal to the agent that the integration is alive.
-func runHeartBeat() {
-	heartBeat := time.NewTicker(time.Duration(args.HeartbeatInterval) * time.Second)
+// runHeartBeat is used in long-running mode to signal to the agent that the integration is alive, until the
+// returned function is called.
+func runHeartBeat() func() {
+	heartBeat := time.NewTicker(time.Duration(args.HeartbeatInterval) * intervalUnit)
+	done := make(chan struct{})
 
 	go func() {
-		for range heartBeat.C {
-			log.Debug("Sending heartBeat")
-			// heartbeat signal for long-running integrations
-			// https://docs.newrelic.com/docs/integrations/integrations-sdk/file-specifications/host-integrations-newer-configuration-format#timeout
-			fmt.Println("{}")
+		for {
+			select {
+			case <-done:
+				return
+			case <-heartBeat.C:
+				log.Debug("Sending heartBeat")
+				// heartbeat signal for long-running integrations
+				// https://docs.newrelic.com/docs/integrations/integrations-sdk/file-specifications/host-integrations-newer-configuration-format#timeout
+				fmt.Println("{}")
+			}
 		}
 	}()
+
+	return func() {
+		heartBeat.Stop()
+		close(done)
+	}
 }
 
 // logInternalStats will print in verbose logs statistics gathered by nrjmx client
 // that can be handy when troubleshooting performance issues.
-func logInternalStats(jmxClient *gojmx.Client) {
-	internalStats, err := jmxClient.GetInternalStats()
+func logInternalStats(jmxClient MBeanSource) {
+	statsClient, ok := jmxClient.(internalStatsSource)
+	if !ok {
+		log.Debug("Internal stats are not supported by %T", jmxClient)
+		return
+	}
+
+	internalStats, err := statsClient.GetInternalStats()
 	if err != nil {
 		log.Error("Failed to collect nrjmx internal stats, %v", err)
 		return
@@ -6,12 +6,17 @@
 package main
 
 import (
+	"io"
 	"testing"
+	"time"
 
 	"github.com/newrelic/infra-integrations-sdk/v3/data/inventory"
 	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
 	"github.com/newrelic/infra-integrations-sdk/v3/persist"
+	"github.com/newrelic/nrjmx/gojmx"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPopulateMetrics(t *testing.T) {
@@ -70,7 +75,7 @@ func TestPopulateInventory(t *testing.T) {
 	}
 
 	i := inventory.New()
-	assert.NoError(t, populateInventory(i, rawInventory))
+	assert.NoError(t, populateInventory(i, rawInventory, testRedactPatterns(t)))
 
 	expectedItems := inventory.Items{
 		"key_1":                 {"value": 1},
@@ -83,3 +88,394 @@ func TestPopulateInventory(t *testing.T) {
 
 	assert.Equal(t, expectedItems, i.Items())
 }
+
+func TestPopulateInventory_Lists(t *testing.T) {
+	var rawInventory = inventory.Item{
+		"data_file_directories": []interface{}{"/var/lib/cassandra/data1", "/var/lib/cassandra/data2"},
+		"seed_provider": []interface{}{
+			map[interface{}]interface{}{
+				"class_name": "org.apache.cassandra.locator.SimpleSeedProvider",
+				"parameters": []interface{}{
+					map[interface{}]interface{}{"seeds": "10.0.0.1,10.0.0.2"},
+				},
+			},
+		},
+		"client_encryption_options": map[interface{}]interface{}{
+			"enabled":         true,
+			"cipher_suites":   []interface{}{"TLS_RSA_WITH_AES_128_CBC_SHA", "TLS_RSA_WITH_AES_256_CBC_SHA"},
+			"keystore_config": map[interface{}]interface{}{"type": "JKS", "keystore_password": "secret"},
+		},
+		"table_properties_ignored": []interface{}{},
+	}
+
+	i := inventory.New()
+	assert.NoError(t, populateInventory(i, rawInventory, testRedactPatterns(t)))
+
+	expectedItems := inventory.Items{
+		"data_file_directories": {"value": "/var/lib/cassandra/data1, /var/lib/cassandra/data2"},
+		"seed_provider": {
+			"0.class_name":         "org.apache.cassandra.locator.SimpleSeedProvider",
+			"0.parameters.0.seeds": "10.0.0.1,10.0.0.2",
+		},
+		"client_encryption_options": {
+			"enabled":                           true,
+			"cipher_suites":                     "TLS_RSA_WITH_AES_128_CBC_SHA, TLS_RSA_WITH_AES_256_CBC_SHA",
+			"keystore_config.type":              "JKS",
+			"keystore_config.keystore_password": "(omitted value)",
+		},
+	}
+
+	assert.Equal(t, expectedItems, i.Items())
+}
+
+func TestPopulateInventory_MaxValueLength(t *testing.T) {
+	setupCollection(t)
+	args.InventoryMaxValueLength = 10
+
+	var rawInventory = inventory.Item{
+		"data_file_directories": []interface{}{"/data1", "/data2", "/data3"},
+		"cluster_name":          "Test",
+		"num_tokens":            256,
+	}
+
+	i := inventory.New()
+	assert.NoError(t, populateInventory(i, rawInventory, testRedactPatterns(t)))
+
+	expectedItems := inventory.Items{
+		"data_file_directories": {"value": "/data1, /d...(truncated)"},
+		"cluster_name":          {"value": "Test"},
+		"num_tokens":            {"value": 256},
+	}
+
+	assert.Equal(t, expectedItems, i.Items())
+}
+
+func TestPopulateThreadPoolMetrics(t *testing.T) {
+	var rawMetrics = map[string]interface{}{
+		"path": "request",
+		"pool": "MutationStage",
+		"org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=ActiveTasks,attr=Value":           3,
+		"org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=CurrentlyBlockedTasks,attr=Count": 1,
+	}
+
+	s := metric.NewSet("CassandraThreadPoolSample", persist.NewInMemoryStore())
+	populateMetrics(s, rawMetrics, threadPoolDefinitions)
+	populateAttributes(s, rawMetrics, threadPoolSampleAttributes)
+
+	sample := s.Metrics
+
+	assert.Equal(t, "request", sample["path"])
+	assert.Equal(t, "MutationStage", sample["pool"])
+	assert.Equal(t, 3.0, sample["activeTasks"])
+	assert.Equal(t, 1.0, sample["currentlyBlockedTasks"])
+	assert.Nil(t, sample["maxPoolSize"])
+}
+
+func TestSplitScopedName(t *testing.T) {
+	key, scopes, ok := splitScopedName(
+		"org.apache.cassandra.metrics:type=ThreadPools,path=request,scope=ReadStage,name=ActiveTasks,attr=Value",
+		threadPoolRegex,
+	)
+	assert.True(t, ok)
+	assert.Equal(t, "org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=ActiveTasks,attr=Value", key)
+	assert.Equal(t, []string{"request", "ReadStage"}, scopes)
+
+	key, scopes, ok = splitScopedName(
+		"org.apache.cassandra.metrics:type=DroppedMessage,scope=MUTATION_REQ,name=Dropped,attr=Count",
+		droppedMessageRegex,
+	)
+	assert.True(t, ok)
+	assert.Equal(t, "org.apache.cassandra.metrics:type=DroppedMessage,scope=*,name=Dropped,attr=Count", key)
+	assert.Equal(t, []string{"MUTATION_REQ"}, scopes)
+
+	_, _, ok = splitScopedName("org.apache.cassandra.metrics:type=Storage,name=Load,attr=Count", droppedMessageRegex)
+	assert.False(t, ok)
+}
+
+func TestPopulateDroppedMessageMetrics(t *testing.T) {
+	var rawMetrics = map[string]interface{}{
+		"verb": "MUTATION_REQ",
+		"org.apache.cassandra.metrics:type=DroppedMessage,scope=*,name=InternalDroppedLatency,attr=99thPercentile":  2500.0,
+		"org.apache.cassandra.metrics:type=DroppedMessage,scope=*,name=CrossNodeDroppedLatency,attr=50thPercentile": 1000.0,
+	}
+
+	s := metric.NewSet("CassandraDroppedMessageSample", persist.NewInMemoryStore())
+	populateMetrics(s, rawMetrics, droppedMessageDefinitions)
+	populateAttributes(s, rawMetrics, droppedMessageSampleAttributes)
+
+	sample := s.Metrics
+
+	assert.Equal(t, "MUTATION_REQ", sample["verb"])
+	assert.Equal(t, 2.5, sample["internalDroppedLatency99thPercentileMilliseconds"])
+	assert.Equal(t, 1.0, sample["crossNodeDroppedLatency50thPercentileMilliseconds"])
+	assert.Nil(t, sample["droppedMessagesPerSecond"])
+}
+
+// setupCollection configures the arguments used by the collector and returns an integration that doesn't
+// write to stdout.
+func setupCollection(t *testing.T) *integration.Integration {
+	t.Helper()
+
+	previousArgs := args
+	t.Cleanup(func() {
+		args = previousArgs
+		_ = pendingHintsSource.Close()
+	})
+
+	args = argumentList{
+		Hostname:            "localhost",
+		Port:                7199,
+		ColumnFamiliesLimit: 20,
+		Interval:            1,
+		HeartbeatInterval:   5,
+		StaleMetrics:        staleMetricsCache,
+		HistogramMode:       histogramModePercentiles,
+	}
+
+	i, err := integration.New(integrationName, integrationVersion, integration.InMemoryStore(), integration.Writer(io.Discard))
+	require.NoError(t, err)
+	return i
+}
+
+// samplesByEventType groups the metric-sets reported for the local entity by event type.
+func samplesByEventType(i *integration.Integration) map[string][]map[string]interface{} {
+	result := make(map[string][]map[string]interface{})
+	for _, ms := range i.LocalEntity().Metrics {
+		eventType := ms.Metrics["event_type"].(string)
+		result[eventType] = append(result[eventType], ms.Metrics)
+	}
+	return result
+}
+
+func TestCollectMetrics(t *testing.T) {
+	i := setupCollection(t)
+	client := newFakeJMXClient(t, "cassandra.yml")
+
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+
+	samples := samplesByEventType(i)
+	require.Len(t, samples["CassandraSample"], 1)
+
+	sample := samples["CassandraSample"][0]
+	assert.Equal(t, "4.0.3", sample["software.version"])
+	assert.Equal(t, "Test Cluster", sample["cluster.name"])
+	assert.Equal(t, "datacenter1", sample["cluster.datacenter"])
+	assert.Equal(t, 8.0, sample["client.connectedNativeClients"])
+	assert.Equal(t, 12.5, sample["query.readRequestsPerSecond"])
+	assert.Equal(t, 5.0, sample["query.readLatency99thPercentileMilliseconds"])
+	assert.Equal(t, 104857600.0, sample["db.keyCacheCapacityBytes"])
+
+	columnFamilies := make(map[string]map[string]interface{})
+	for _, cf := range samples["CassandraColumnFamilySample"] {
+		columnFamilies[cf["db.keyspaceAndColumnFamily"].(string)] = cf
+	}
+	require.Len(t, columnFamilies, 3, "system keyspace should be discarded")
+	assert.Equal(t, "users", columnFamilies["users.profiles"]["db.keyspace"])
+	assert.Equal(t, "profiles", columnFamilies["users.profiles"]["db.columnFamily"])
+	assert.Equal(t, "4.0.3", columnFamilies["users.profiles"]["software.version"])
+	assert.Equal(t, 4.0, columnFamilies["users.profiles"]["db.liveSSTableCount"])
+	assert.Equal(t, 1.2, columnFamilies["users.profiles"]["query.readLatency99thPercentileMilliseconds"])
+	assert.Equal(t, 7.0, columnFamilies["orders.items"]["db.liveSSTableCount"])
+
+	assert.Empty(t, samples["CassandraThreadPoolSample"], "thread pool samples are opt-in")
+	assert.Empty(t, samples["CassandraDroppedMessageSample"], "dropped message samples are opt-in")
+}
+
+func TestCollectMetrics_OptInSamples(t *testing.T) {
+	i := setupCollection(t)
+	args.ThreadPoolMetrics = true
+	args.DroppedMessageMetrics = true
+	client := newFakeJMXClient(t, "cassandra.yml")
+
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+
+	samples := samplesByEventType(i)
+
+	threadPools := make(map[string]map[string]interface{})
+	for _, tp := range samples["CassandraThreadPoolSample"] {
+		threadPools[tp["path"].(string)+"."+tp["pool"].(string)] = tp
+	}
+	require.Len(t, threadPools, 2)
+	assert.Equal(t, 2.0, threadPools["request.ReadStage"]["activeTasks"])
+	assert.Equal(t, 5.0, threadPools["request.ReadStage"]["pendingTasks"])
+	assert.Equal(t, 0.0, threadPools["request.ReadStage"]["currentlyBlockedTasks"])
+	assert.Equal(t, 3.0, threadPools["internal.CompactionExecutor"]["pendingTasks"])
+
+	droppedMessages := make(map[string]map[string]interface{})
+	for _, dm := range samples["CassandraDroppedMessageSample"] {
+		droppedMessages[dm["verb"].(string)] = dm
+	}
+	require.Len(t, droppedMessages, 2)
+	assert.Equal(t, 2.5, droppedMessages["MUTATION_REQ"]["internalDroppedLatency99thPercentileMilliseconds"])
+}
+
+func TestCollectMetrics_ColumnFamiliesLimit(t *testing.T) {
+	i := setupCollection(t)
+	args.ColumnFamiliesLimit = 2
+	client := newFakeJMXClient(t, "cassandra.yml")
+
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+
+	assert.Len(t, samplesByEventType(i)["CassandraColumnFamilySample"], 2)
+}
+
+func TestCollectMetrics_ColumnFamiliesDisabled(t *testing.T) {
+	i := setupCollection(t)
+	args.ColumnFamiliesLimit = 0
+	client := newFakeJMXClient(t, "cassandra.yml")
+
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+
+	assert.Empty(t, samplesByEventType(i)["CassandraColumnFamilySample"])
+}
+
+func TestCollectMetrics_Filtering(t *testing.T) {
+	i := setupCollection(t)
+	client := newFakeJMXClient(t, "cassandra.yml")
+
+	config, err := LoadFilteringConfig(`
+exclude:
+  - "*"
+include:
+  - client.connectedNativeClients
+  - db.liveSSTableCount
+`)
+	require.NoError(t, err)
+
+	definitions := NewDefinitions()
+	definitions.Filter(config)
+
+	require.NoError(t, collectMetrics(i, client, definitions))
+
+	samples := samplesByEventType(i)
+	sample := samples["CassandraSample"][0]
+	assert.Equal(t, 8.0, sample["client.connectedNativeClients"])
+	assert.Nil(t, sample["software.version"])
+	assert.Nil(t, sample["query.readRequestsPerSecond"])
+
+	for _, cf := range samples["CassandraColumnFamilySample"] {
+		assert.NotNil(t, cf["db.liveSSTableCount"])
+		assert.Nil(t, cf["query.readRequestsPerSecond"])
+	}
+}
+
+func TestCollectMetrics_FilteringByTable(t *testing.T) {
+	i := setupCollection(t)
+	args.ColumnFamiliesLimit = 2
+	client := newFakeJMXClient(t, "cassandra.yml")
+
+	config, err := LoadFilteringConfig(`
+exclude:
+  - keyspace: users
+    table: "sess*"
+  - metric: "query.*"
+    sample: CassandraColumnFamilySample
+include:
+  - metric: query.readLatency99thPercentileMilliseconds
+    keyspace: users
+`)
+	require.NoError(t, err)
+
+	definitions := NewDefinitions()
+	definitions.Filter(config)
+
+	require.NoError(t, collectMetrics(i, client, definitions))
+
+	samples := samplesByEventType(i)
+	assert.Equal(t, 5.0, samples["CassandraSample"][0]["query.readLatency99thPercentileMilliseconds"])
+
+	// users.sessions is fully excluded, so it does not count towards the column families limit.
+	columnFamilies := make(map[string]map[string]interface{})
+	for _, cf := range samples["CassandraColumnFamilySample"] {
+		columnFamilies[cf["db.keyspaceAndColumnFamily"].(string)] = cf
+	}
+	require.Len(t, columnFamilies, 2)
+	assert.Contains(t, columnFamilies, "orders.items")
+	assert.Equal(t, 1.2, columnFamilies["users.profiles"]["query.readLatency99thPercentileMilliseconds"])
+	assert.Nil(t, columnFamilies["users.profiles"]["query.readRequestsPerSecond"])
+	assert.Equal(t, 4.0, columnFamilies["users.profiles"]["db.liveSSTableCount"])
+}
+
+func TestCollectMetrics_RecoverableErrors(t *testing.T) {
+	i := setupCollection(t)
+	client := newFakeJMXClient(t, "cassandra.yml")
+	client.inject("org.apache.cassandra.metrics:type=Client,*", faultJMXError)
+	client.inject("org.apache.cassandra.metrics:type=ClientRequest,*", faultMissingAttributes)
+	client.inject("org.apache.cassandra.metrics:type=Table,keyspace=orders,*", faultJMXError)
+
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+
+	samples := samplesByEventType(i)
+	sample := samples["CassandraSample"][0]
+	assert.Nil(t, sample["client.connectedNativeClients"])
+	assert.Nil(t, sample["query.readRequestsPerSecond"])
+	assert.Equal(t, 1048576.0, sample["db.loadBytes"])
+
+	assert.Len(t, samples["CassandraColumnFamilySample"], 2)
+}
+
+func TestCollectMetrics_Timeout(t *testing.T) {
+	i := setupCollection(t)
+	client := newFakeJMXClient(t, "cassandra.yml")
+	client.inject("org.apache.cassandra.metrics:type=Storage,*", faultTimeout)
+
+	err := collectMetrics(i, client, NewDefinitions())
+
+	var connErr *gojmx.JMXConnectionError
+	assert.ErrorAs(t, err, &connErr)
+}
+
+func TestCollectMetricsEachInterval_ClientNotRunning(t *testing.T) {
+	i := setupCollection(t)
+	client := newFakeJMXClient(t, "cassandra.yml")
+	client.inject("org.apache.cassandra.metrics:type=Storage,*", faultClientError)
+	intervalUnit = time.Millisecond
+	t.Cleanup(func() { intervalUnit = time.Second })
+	args.HeartbeatInterval = 1000
+
+	// The first cycle fails and the nrjmx sub-process is terminated, the next one should stop the collection.
+	err := collectMetricsEachInterval(i, client, NewDefinitions())
+
+	assert.ErrorIs(t, err, errNRJMXNotRunning)
+}
+
+func TestRunMetricCollection(t *testing.T) {
+	i := setupCollection(t)
+	args.EnableInternalStats = true
+	args.MetricsFilter = `
+exclude:
+  - db.loadBytes
+`
+	client := newFakeJMXClient(t, "cassandra.yml")
+
+	require.NoError(t, runMetricCollection(i, client))
+
+	sample := samplesByEventType(i)["CassandraSample"][0]
+	assert.Equal(t, 8.0, sample["client.connectedNativeClients"])
+	assert.Nil(t, sample["db.loadBytes"])
+}
+
+func TestRunMetricCollection_StrictMetricsFilter(t *testing.T) {
+	i := setupCollection(t)
+	args.MetricsFilter = `
+exclude:
+  - db.loadByte
+`
+	client := newFakeJMXClient(t, "cassandra.yml")
+
+	// Rules matching no metric are only logged by default.
+	require.NoError(t, runMetricCollection(i, client))
+
+	args.MetricsFilterStrict = true
+	err := runMetricCollection(i, client)
+	assert.ErrorIs(t, err, errInvalidMetricsFilter)
+	assert.ErrorContains(t, err, `did you mean "db.loadBytes"?`)
+}
+
+func TestOpenTransport_Unsupported(t *testing.T) {
+	setupCollection(t)
+	args.Transport = "thrift"
+
+	_, err := openTransport()
+	assert.EqualError(t, err, `unsupported transport: "thrift", valid values are: "jmx", "jolokia", "cql"`)
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/inventory"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+// confFile is a file of the Cassandra conf directory, besides cassandra.yaml, reported in the inventory.
+// Its settings are reported as items named '<prefix>.<setting>'.
+type confFile struct {
+	name   string
+	prefix string
+	parse  func(content string) map[string]interface{}
+}
+
+// confFiles are the files read from the conf directory, the missing ones are skipped as they depend on the
+// Cassandra and Java versions.
+var confFiles = []confFile{
+	{name: "jvm.options", prefix: "jvm_options", parse: parseJVMOptionsFile},
+	{name: "jvm-server.options", prefix: "jvm_server_options", parse: parseJVMOptionsFile},
+	{name: "jvm11-server.options", prefix: "jvm11_server_options", parse: parseJVMOptionsFile},
+	{name: "jvm17-server.options", prefix: "jvm17_server_options", parse: parseJVMOptionsFile},
+	{name: "cassandra-env.sh", prefix: "cassandra_env", parse: parseCassandraEnv},
+	{name: "cassandra-rackdc.properties", prefix: "cassandra_rackdc", parse: parseProperties},
+}
+
+var (
+	// jvmSizeOptionRegex matches the heap and stack size options, e.g. '-Xmx8G'.
+	jvmSizeOptionRegex = regexp.MustCompile(`^-X(mx|ms|mn|ss)(\d.*)$`)
+	// shellAssignmentRegex matches the assignments of the upper case variables in cassandra-env.sh,
+	// e.g. 'MAX_HEAP_SIZE="8G"'. The lower case ones are local variables used to compute them.
+	shellAssignmentRegex = regexp.MustCompile(`^\s*(?:export\s+)?([A-Z_][A-Z0-9_]*)=(.*)$`)
+	// jvmOptsReferenceRegex matches the references to the JVM_OPTS variable being appended to.
+	jvmOptsReferenceRegex = regexp.MustCompile(`\$\{?JVM_OPTS\}?`)
+)
+
+// getConfInventory reads the files in the conf directory of cassandra.yaml and returns their settings by
+// inventory item name.
+func getConfInventory(confDir string) map[string]interface{} {
+	items := make(map[string]interface{})
+
+	for _, file := range confFiles {
+		content, err := os.ReadFile(filepath.Join(confDir, file.name))
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Warn("Failed to read the inventory from %s: %v", file.name, err)
+			}
+			continue
+		}
+
+		for name, value := range file.parse(string(content)) {
+			items[inventoryPath(file.prefix, name)] = value
+		}
+	}
+	return items
+}
+
+// populateConfInventory sets an inventory item with a 'value' field for each setting of the conf files.
+func populateConfInventory(i *inventory.Inventory, items map[string]interface{}, redact redactPatterns) {
+	for key, value := range items {
+		setValue(i, key, "value", truncateValue(value, args.InventoryMaxValueLength), redact)
+	}
+}
+
+// parseJVMOptionsFile parses the JVM options files, with an option per line.
+func parseJVMOptionsFile(content string) map[string]interface{} {
+	options := make(map[string]interface{})
+	for _, line := range confLines(content, "#") {
+		addJVMOption(options, line)
+	}
+	return options
+}
+
+// addJVMOption adds the option named as it is set:
+//   - system properties by their name, '-Dcassandra.ring_delay_ms=30000' is 'cassandra.ring_delay_ms'.
+//   - advanced options prefixed by 'XX', '-XX:+UseG1GC' is 'XX.UseG1GC' with true as value.
+//   - heap and stack sizes by the option, '-Xmx8G' is 'Xmx' with '8G' as value.
+//   - other options without dashes, split from their value on the first ':', '=' or space.
+//
+// Repeated options override the previous value, like in the JVM, but for the '--' ones like '--add-exports',
+// whose values are joined.
+func addJVMOption(options map[string]interface{}, option string) {
+	var name string
+	var value interface{} = true
+
+	switch {
+	case strings.HasPrefix(option, "-D"):
+		name, value = splitJVMOption(option[2:], "=")
+	case strings.HasPrefix(option, "-XX:+"):
+		name = "XX." + option[5:]
+	case strings.HasPrefix(option, "-XX:-"):
+		name, value = "XX."+option[5:], false
+	case strings.HasPrefix(option, "-XX:"):
+		name, value = splitJVMOption(option[4:], "=")
+		name = "XX." + name
+	case jvmSizeOptionRegex.MatchString(option):
+		match := jvmSizeOptionRegex.FindStringSubmatch(option)
+		name, value = "X"+match[1], match[2]
+	case strings.HasPrefix(option, "--"):
+		name, value = splitJVMOption(option[2:], "= ")
+		if previous, ok := options[name].(string); ok {
+			value = previous + ", " + value.(string)
+		}
+	default:
+		name, value = splitJVMOption(strings.TrimPrefix(option, "-"), ":= ")
+	}
+
+	options[name] = value
+}
+
+// splitJVMOption splits the option name from its value on the first of the separators. Options without value
+// are flags, reported as true.
+func splitJVMOption(option, separators string) (string, interface{}) {
+	idx := strings.IndexAny(option, separators)
+	if idx < 0 {
+		return option, true
+	}
+	return option[:idx], strings.TrimSpace(option[idx+1:])
+}
+
+// parseCassandraEnv parses the variables assigned in cassandra-env.sh. The script is not run, so the assignments
+// made inside if, case and loop blocks are skipped, as their value depends on the conditions, and the references to
+// other variables are not expanded. The options appended to JVM_OPTS are reported as 'JVM_OPTS.<option>' as in the
+// JVM options files.
+func parseCassandraEnv(content string) map[string]interface{} {
+	variables := make(map[string]interface{})
+	jvmOpts := make(map[string]interface{})
+
+	depth := 0
+	for _, line := range confLines(content, "#") {
+		conditional := depth > 0
+		depth = max(depth+shellBlockDepth(line), 0)
+		if conditional || depth > 0 {
+			continue
+		}
+
+		match := shellAssignmentRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		name, value := match[1], unquoteShellValue(match[2])
+		if name != "JVM_OPTS" {
+			variables[name] = value
+			continue
+		}
+
+		for _, option := range strings.Fields(jvmOptsReferenceRegex.ReplaceAllString(value, "")) {
+			addJVMOption(jvmOpts, option)
+		}
+	}
+
+	for name, value := range jvmOpts {
+		variables["JVM_OPTS."+name] = value
+	}
+	return variables
+}
+
+// shellBlockDepth returns how many if, case and loop blocks the line opens, negative when it closes them.
+func shellBlockDepth(line string) int {
+	depth := 0
+	for _, command := range strings.FieldsFunc(line, func(r rune) bool { return r == ';' || r == '&' || r == '|' }) {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "if", "case", "for", "while", "until":
+			depth++
+		case "fi", "esac", "done":
+			depth--
+		}
+	}
+	return depth
+}
+
+// unquoteShellValue removes the quotes around the value, or the trailing comment of unquoted values.
+func unquoteShellValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if idx := strings.Index(value, " #"); idx >= 0 {
+		value = value[:idx]
+	}
+	return strings.TrimSpace(value)
+}
+
+// parseProperties parses a Java properties file with a 'key=value' or 'key: value' setting per line.
+func parseProperties(content string) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for _, line := range confLines(content, "#!") {
+		idx := strings.IndexAny(line, "=:")
+		if idx < 0 {
+			properties[line] = ""
+			continue
+		}
+		properties[strings.TrimSpace(line[:idx])] = strings.TrimSpace(line[idx+1:])
+	}
+	return properties
+}
+
+// confLines returns the trimmed lines of the content, skipping the empty ones and the comments.
+func confLines(content string, commentPrefixes string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.ContainsRune(commentPrefixes, rune(line[0])) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
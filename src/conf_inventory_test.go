@@ -0,0 +1,94 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"path"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/inventory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConfInventory(t *testing.T) {
+	items := getConfInventory(path.Join("testdata", "conf"))
+
+	assert.Equal(t, map[string]interface{}{
+		"jvm_server_options.XX.ThreadPriorityPolicy":                    "42",
+		"jvm_server_options.Xms":                                        "4G",
+		"jvm_server_options.Xmx":                                        "4G",
+		"jvm_server_options.cassandra.ring_delay_ms":                    "30000",
+		"jvm_server_options.com.sun.management.jmxremote.password.file": "/etc/cassandra/jmxremote.password",
+		"jvm_server_options.XX.HeapDumpOnOutOfMemoryError":              true,
+		"jvm_server_options.XX.UseBiasedLocking":                        false,
+		"jvm_server_options.ea":                                         true,
+		"jvm11_server_options.XX.UseG1GC":                               true,
+		"jvm11_server_options.XX.MaxGCPauseMillis":                      "300",
+		"jvm11_server_options.Xlog":                                     "gc=info,heap*=trace,age*=debug:file=/var/log/cassandra/gc.log:time,uptime,pid,tid,level:filecount=10,filesize=10485760",
+		"jvm11_server_options.add-exports":                              "java.base/jdk.internal.misc=ALL-UNNAMED, java.rmi/sun.rmi.registry=ALL-UNNAMED",
+		"cassandra_env.JMX_PORT":                                        "7199",
+		"cassandra_env.JMX_PASSWORD":                                    "s3cr3t",
+		"cassandra_env.JVM_OPTS.java.rmi.server.hostname":               "10.0.0.1",
+		"cassandra_env.JVM_OPTS.XX.UseCondCardMark":                     true,
+		"cassandra_rackdc.dc":                                           "dc1",
+		"cassandra_rackdc.rack":                                         "rack1",
+		"cassandra_rackdc.dc_suffix":                                    "_east",
+	}, items)
+}
+
+func TestParseCassandraEnv_Conditionals(t *testing.T) {
+	content := `
+MAX_HEAP_SIZE="8G"
+case "$(uname)" in
+    Linux)
+        MAX_HEAP_SIZE="4G"
+    ;;
+esac
+if [ "$JVM_VERSION" \> "11" ]; then
+    if [ -n "$GC" ]; then
+        JVM_OPTS="$JVM_OPTS -XX:+UseG1GC"
+    fi
+    JVM_OPTS="$JVM_OPTS -XX:MaxGCPauseMillis=300"
+elif [ -n "$CMS" ]; then JVM_OPTS="$JVM_OPTS -XX:+UseConcMarkSweepGC"
+fi
+for opt in $EXTRA; do JVM_OPTS="$JVM_OPTS $opt"; done
+[ -z "$JMX_PORT" ] && JMX_PORT="7199"
+JVM_OPTS="$JVM_OPTS -Djava.net.preferIPv4Stack=true"
+`
+
+	assert.Equal(t, map[string]interface{}{
+		"MAX_HEAP_SIZE":                     "8G",
+		"JVM_OPTS.java.net.preferIPv4Stack": "true",
+	}, parseCassandraEnv(content))
+}
+
+func TestGetConfInventory_MissingDirectory(t *testing.T) {
+	assert.Empty(t, getConfInventory(path.Join(t.TempDir(), "conf")))
+}
+
+func TestPopulateConfInventory(t *testing.T) {
+	i := inventory.New()
+	populateConfInventory(i, getConfInventory(path.Join("testdata", "conf")), testRedactPatterns(t))
+
+	items := i.Items()
+	assert.Equal(t, inventory.Item{"value": "4G"}, items["jvm_server_options.Xmx"])
+	assert.Equal(t, inventory.Item{"value": "(omitted value)"}, items["jvm_server_options.com.sun.management.jmxremote.password.file"])
+	assert.Equal(t, inventory.Item{"value": "(omitted value)"}, items["cassandra_env.JMX_PASSWORD"])
+	assert.Equal(t, inventory.Item{"value": "dc1"}, items["cassandra_rackdc.dc"])
+}
+
+func TestRunInventoryCollection_ConfDirectory(t *testing.T) {
+	i := setupCollection(t)
+	args.ConfigPath = path.Join("testdata", "conf", "cassandra.yaml")
+
+	require.NoError(t, runInventoryCollection(i, nil))
+
+	items := i.LocalEntity().Inventory.Items()
+	assert.Equal(t, inventory.Item{"value": "Test Cluster"}, items["cluster_name"])
+	assert.Equal(t, inventory.Item{"value": true}, items["jvm11_server_options.XX.UseG1GC"])
+	assert.Equal(t, inventory.Item{"value": "rack1"}, items["cassandra_rackdc.rack"])
+}
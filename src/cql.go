@@ -0,0 +1,639 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+// CQL native protocol v4 opcodes and constants, see
+// https://github.com/apache/cassandra/blob/trunk/doc/native_protocol_v4.spec
+const (
+	cqlVersion         = 0x04
+	cqlResponseVersion = 0x84
+
+	cqlOpError         = 0x00
+	cqlOpStartup       = 0x01
+	cqlOpReady         = 0x02
+	cqlOpAuthenticate  = 0x03
+	cqlOpQuery         = 0x07
+	cqlOpResult        = 0x08
+	cqlOpAuthChallenge = 0x0E
+	cqlOpAuthResponse  = 0x0F
+	cqlOpAuthSuccess   = 0x10
+
+	cqlResultRows = 0x0002
+
+	cqlConsistencyLocalOne = 0x000A
+
+	cqlQueryFlagPageSize        = 0x04
+	cqlQueryFlagWithPagingState = 0x08
+	cqlPageSize                 = 5000
+
+	cqlFlagGlobalTablesSpec = 0x0001
+	cqlFlagHasMorePages     = 0x0002
+	cqlFlagNoMetadata       = 0x0004
+
+	cqlHeaderFlagCompression   = 0x01
+	cqlHeaderFlagTracing       = 0x02
+	cqlHeaderFlagCustomPayload = 0x04
+	cqlHeaderFlagWarning       = 0x08
+
+	cqlHeaderLength  = 9
+	cqlMaxBodyLength = 256 * 1024 * 1024
+)
+
+// CQL column types.
+const (
+	cqlTypeCustom    = 0x0000
+	cqlTypeASCII     = 0x0001
+	cqlTypeBigint    = 0x0002
+	cqlTypeBlob      = 0x0003
+	cqlTypeBoolean   = 0x0004
+	cqlTypeCounter   = 0x0005
+	cqlTypeDouble    = 0x0007
+	cqlTypeFloat     = 0x0008
+	cqlTypeInt       = 0x0009
+	cqlTypeTimestamp = 0x000B
+	cqlTypeUUID      = 0x000C
+	cqlTypeVarchar   = 0x000D
+	cqlTypeTimeUUID  = 0x000F
+	cqlTypeInet      = 0x0010
+	cqlTypeSmallint  = 0x0013
+	cqlTypeTinyint   = 0x0014
+	cqlTypeList      = 0x0020
+	cqlTypeMap       = 0x0021
+	cqlTypeSet       = 0x0022
+	cqlTypeUDT       = 0x0030
+	cqlTypeTuple     = 0x0031
+)
+
+var (
+	errCQLUnexpectedResponse = errors.New("unexpected CQL response")
+	errCQLMalformed          = errors.New("malformed CQL response")
+)
+
+// CQLError is an error response of the node, e.g. for a query to a table that doesn't exist in its version.
+type CQLError struct {
+	Code    int32
+	Message string
+}
+
+func (e *CQLError) Error() string {
+	return fmt.Sprintf("CQL error 0x%04x: %s", uint32(e.Code), e.Message)
+}
+
+// CQLSource runs CQL queries on the node, for the data only exposed through CQL like the virtual tables.
+type CQLSource interface {
+	// Query runs the statement and returns its rows as maps of the column names to their values.
+	Query(statement string) ([]map[string]interface{}, error)
+	// Close releases the connection with the node.
+	Close() error
+}
+
+// cqlConfig keeps the settings to reach the CQL native transport.
+type cqlConfig struct {
+	Address            string
+	Username           string
+	Password           string
+	Timeout            time.Duration
+	TLS                bool
+	CACert             string
+	InsecureSkipVerify bool
+}
+
+// cqlClient is a minimal CQL native protocol v4 client, supporting the password authentication and the queries
+// without parameters needed to read the system tables. It runs a single request at a time on its connection, so it
+// doesn't need the pools, prepared statements and topology discovery of a driver like gocql, which would add a large
+// dependency to the integration for a few queries per collection.
+type cqlClient struct {
+	conn     net.Conn
+	timeout  time.Duration
+	stream   uint16
+	pageSize int32
+	// closed is set when the connection has been closed, either by Close or after a failed request.
+	closed bool
+}
+
+var _ CQLSource = (*cqlClient)(nil)
+
+// cqlType is the type of a result column, with the types of the elements of collections.
+type cqlType struct {
+	id       uint16
+	elements []cqlType
+}
+
+// newCQLClient connects to the node and performs the startup and authentication of the connection.
+func newCQLClient(config cqlConfig) (*cqlClient, error) {
+	conn, err := dialCQL(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &cqlClient{conn: conn, timeout: config.Timeout, pageSize: cqlPageSize}
+	if err := client.startup(config.Username, config.Password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// cqlHandshake checks that the native transport accepts connections: the node has to answer the startup of the
+// connection, either ready or requesting authentication.
+func cqlHandshake(config cqlConfig) error {
+	conn, err := dialCQL(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	client := &cqlClient{conn: conn, timeout: config.Timeout}
+	var body bytes.Buffer
+	writeCQLStringMap(&body, map[string]string{"CQL_VERSION": "3.0.0"})
+
+	opcode, _, err := client.request(cqlOpStartup, body.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to start CQL connection: %w", err)
+	}
+	if opcode != cqlOpReady && opcode != cqlOpAuthenticate {
+		return fmt.Errorf("%w: opcode 0x%02x after startup", errCQLUnexpectedResponse, opcode)
+	}
+	return nil
+}
+
+// dialCQL opens the connection with the native transport, using TLS if configured.
+func dialCQL(config cqlConfig) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+
+	var conn net.Conn
+	var err error
+	if config.TLS {
+		tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+		if config.CACert != "" {
+			caCert, err := os.ReadFile(config.CACert)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CQL CA certificate: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+				return nil, fmt.Errorf("failed to parse CQL CA certificate: %s", config.CACert)
+			}
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", config.Address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", config.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to CQL endpoint %s: %w", config.Address, err)
+	}
+	return conn, nil
+}
+
+// startup initializes the connection, answering the authentication challenge with the credentials if requested.
+func (c *cqlClient) startup(username, password string) error {
+	var body bytes.Buffer
+	writeCQLStringMap(&body, map[string]string{"CQL_VERSION": "3.0.0"})
+
+	opcode, response, err := c.request(cqlOpStartup, body.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to start CQL connection: %w", err)
+	}
+
+	if opcode == cqlOpAuthenticate {
+		// SASL PLAIN token used by the PasswordAuthenticator.
+		token := append([]byte{0}, []byte(username)...)
+		token = append(token, 0)
+		token = append(token, []byte(password)...)
+
+		body.Reset()
+		writeCQLBytes(&body, token)
+		if opcode, response, err = c.request(cqlOpAuthResponse, body.Bytes()); err != nil {
+			return fmt.Errorf("failed to authenticate CQL connection: %w", err)
+		}
+		if opcode == cqlOpAuthChallenge {
+			return fmt.Errorf("failed to authenticate CQL connection: unsupported authentication challenge")
+		}
+		if opcode != cqlOpAuthSuccess {
+			return fmt.Errorf("%w: opcode 0x%02x after authentication", errCQLUnexpectedResponse, opcode)
+		}
+		return nil
+	}
+
+	if opcode != cqlOpReady {
+		return fmt.Errorf("%w: opcode 0x%02x after startup, body: %q", errCQLUnexpectedResponse, opcode, response)
+	}
+	return nil
+}
+
+// Query runs the statement with LOCAL_ONE consistency. The rows are read in pages of cqlPageSize rows, requesting
+// the next page with the paging state of the previous one until the node reports no more pages.
+func (c *cqlClient) Query(statement string) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	var pagingState []byte
+	for {
+		page, next, err := c.queryPage(statement, pagingState)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, page...)
+		if len(next) == 0 {
+			return rows, nil
+		}
+		pagingState = next
+	}
+}
+
+// queryPage returns the rows of the page starting at the paging state, the first one when nil, and the paging state
+// of the next page, nil for the last one.
+func (c *cqlClient) queryPage(statement string, pagingState []byte) ([]map[string]interface{}, []byte, error) {
+	var body bytes.Buffer
+	writeCQLLongString(&body, statement)
+	_ = binary.Write(&body, binary.BigEndian, uint16(cqlConsistencyLocalOne))
+	flags := byte(cqlQueryFlagPageSize)
+	if pagingState != nil {
+		flags |= cqlQueryFlagWithPagingState
+	}
+	body.WriteByte(flags)
+	_ = binary.Write(&body, binary.BigEndian, c.pageSize)
+	if pagingState != nil {
+		writeCQLBytes(&body, pagingState)
+	}
+
+	opcode, response, err := c.request(cqlOpQuery, body.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query %q: %w", statement, err)
+	}
+	if opcode != cqlOpResult {
+		return nil, nil, fmt.Errorf("%w: opcode 0x%02x for query %q", errCQLUnexpectedResponse, opcode, statement)
+	}
+
+	rows, next, err := parseCQLResult(response)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the result of %q: %w", statement, err)
+	}
+	return rows, next, nil
+}
+
+// Close closes the connection, if not already closed after a failed request.
+func (c *cqlClient) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
+
+// lazyCQLSource connects on its first query and keeps the connection for the next ones, so the collections of
+// long-running mode share it. The connection is dropped when a query fails for another reason than a CQL error, and
+// opened again by the next query.
+type lazyCQLSource struct {
+	open   func() (CQLSource, error)
+	client CQLSource
+}
+
+var _ CQLSource = (*lazyCQLSource)(nil)
+
+func (s *lazyCQLSource) Query(statement string) ([]map[string]interface{}, error) {
+	if s.client == nil {
+		client, err := s.open()
+		if err != nil {
+			return nil, err
+		}
+		s.client = client
+	}
+
+	rows, err := s.client.Query(statement)
+	var cqlErr *CQLError
+	if err != nil && !errors.As(err, &cqlErr) {
+		if closeErr := s.Close(); closeErr != nil {
+			log.Debug("Failed to close the CQL connection: %v", closeErr)
+		}
+	}
+	return rows, err
+}
+
+// Close closes the connection, if open.
+func (s *lazyCQLSource) Close() error {
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
+
+// request sends a frame and returns the opcode and body of the response. Error responses are returned as errors.
+// The connection is closed after any other failure, since the stream may be left in the middle of a frame.
+func (c *cqlClient) request(opcode byte, body []byte) (byte, []byte, error) {
+	if c.closed {
+		return 0, nil, net.ErrClosed
+	}
+
+	responseOpcode, response, err := c.roundTrip(opcode, body)
+	if err != nil {
+		c.Close()
+		return 0, nil, err
+	}
+	if responseOpcode == cqlOpError {
+		return 0, nil, parseCQLError(response)
+	}
+	return responseOpcode, response, nil
+}
+
+// roundTrip writes the frame and reads the response to it, skipping the tracing id, warnings and custom payload
+// flagged in its header.
+func (c *cqlClient) roundTrip(opcode byte, body []byte) (byte, []byte, error) {
+	if c.timeout > 0 {
+		if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	// Negative stream ids are reserved for the events sent by the server.
+	c.stream = (c.stream + 1) & 0x7FFF
+	header := make([]byte, cqlHeaderLength)
+	header[0] = cqlVersion
+	binary.BigEndian.PutUint16(header[2:4], c.stream)
+	header[4] = opcode
+	binary.BigEndian.PutUint32(header[5:9], uint32(len(body)))
+
+	if _, err := c.conn.Write(append(header, body...)); err != nil {
+		return 0, nil, err
+	}
+
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return 0, nil, err
+	}
+	if header[0] != cqlResponseVersion {
+		return 0, nil, fmt.Errorf("%w: protocol version 0x%02x", errCQLUnexpectedResponse, header[0])
+	}
+	if stream := binary.BigEndian.Uint16(header[2:4]); stream != c.stream {
+		return 0, nil, fmt.Errorf("%w: stream %d for request stream %d", errCQLUnexpectedResponse, stream, c.stream)
+	}
+	length := binary.BigEndian.Uint32(header[5:9])
+	if length > cqlMaxBodyLength {
+		return 0, nil, fmt.Errorf("%w: body of %d bytes", errCQLMalformed, length)
+	}
+
+	response := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, response); err != nil {
+		return 0, nil, err
+	}
+
+	flags := header[1]
+	if flags&cqlHeaderFlagCompression != 0 {
+		// Compression is never negotiated in the startup of the connection.
+		return 0, nil, fmt.Errorf("%w: compressed body", errCQLUnexpectedResponse)
+	}
+	r := &cqlReader{buf: response}
+	if flags&cqlHeaderFlagTracing != 0 {
+		r.next(16)
+	}
+	if flags&cqlHeaderFlagWarning != 0 {
+		for n := r.short(); n > 0 && r.err == nil; n-- {
+			log.Debug("CQL warning: %s", r.string())
+		}
+	}
+	if flags&cqlHeaderFlagCustomPayload != 0 {
+		for n := r.short(); n > 0 && r.err == nil; n-- {
+			r.string()
+			r.bytes()
+		}
+	}
+	if r.err != nil {
+		return 0, nil, fmt.Errorf("%w: flagged prefix of the body", errCQLMalformed)
+	}
+	return header[4], r.buf, nil
+}
+
+func parseCQLError(body []byte) error {
+	r := &cqlReader{buf: body}
+	code := r.int()
+	message := r.string()
+	if r.err != nil {
+		return fmt.Errorf("%w: error response", errCQLMalformed)
+	}
+	return &CQLError{Code: code, Message: message}
+}
+
+// parseCQLResult returns the rows of a RESULT body and the paging state of the next page, if any. Other kinds of
+// results have no rows.
+func parseCQLResult(body []byte) ([]map[string]interface{}, []byte, error) {
+	r := &cqlReader{buf: body}
+	if kind := r.int(); kind != cqlResultRows {
+		return nil, nil, r.err
+	}
+
+	flags := r.int()
+	columnsCount := int(r.int())
+	var pagingState []byte
+	if flags&cqlFlagHasMorePages != 0 {
+		pagingState = r.bytes()
+	}
+	if flags&cqlFlagNoMetadata != 0 {
+		return nil, nil, fmt.Errorf("%w: rows without metadata", errCQLMalformed)
+	}
+	if flags&cqlFlagGlobalTablesSpec != 0 {
+		r.string()
+		r.string()
+	}
+
+	names := make([]string, columnsCount)
+	types := make([]cqlType, columnsCount)
+	for i := 0; i < columnsCount && r.err == nil; i++ {
+		if flags&cqlFlagGlobalTablesSpec == 0 {
+			r.string()
+			r.string()
+		}
+		names[i] = r.string()
+		types[i] = r.option()
+	}
+
+	rowsCount := int(r.int())
+	var rows []map[string]interface{}
+	for i := 0; i < rowsCount && r.err == nil; i++ {
+		row := make(map[string]interface{}, columnsCount)
+		for j := 0; j < columnsCount; j++ {
+			row[names[j]] = decodeCQLValue(types[j], r.bytes())
+		}
+		rows = append(rows, row)
+	}
+
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	return rows, pagingState, nil
+}
+
+// decodeCQLValue converts the serialized value to a Go value. Unsupported types are returned as hex strings.
+func decodeCQLValue(t cqlType, value []byte) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	switch t.id {
+	case cqlTypeASCII, cqlTypeVarchar:
+		return string(value)
+	case cqlTypeBoolean:
+		return len(value) == 1 && value[0] != 0
+	case cqlTypeBigint, cqlTypeCounter, cqlTypeInt, cqlTypeSmallint, cqlTypeTinyint:
+		return decodeCQLInteger(value)
+	case cqlTypeDouble:
+		if len(value) == 8 {
+			return math.Float64frombits(binary.BigEndian.Uint64(value))
+		}
+	case cqlTypeFloat:
+		if len(value) == 4 {
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(value)))
+		}
+	case cqlTypeTimestamp:
+		if len(value) == 8 {
+			return time.UnixMilli(int64(binary.BigEndian.Uint64(value))).UTC()
+		}
+	case cqlTypeUUID, cqlTypeTimeUUID:
+		if len(value) == 16 {
+			return fmt.Sprintf("%x-%x-%x-%x-%x", value[0:4], value[4:6], value[6:8], value[8:10], value[10:16])
+		}
+	case cqlTypeInet:
+		return net.IP(value).String()
+	case cqlTypeList, cqlTypeSet:
+		r := &cqlReader{buf: value}
+		result := []interface{}{}
+		for n := r.int(); n > 0 && r.err == nil; n-- {
+			result = append(result, decodeCQLValue(t.elements[0], r.bytes()))
+		}
+		return result
+	case cqlTypeMap:
+		r := &cqlReader{buf: value}
+		result := map[string]interface{}{}
+		for n := r.int(); n > 0 && r.err == nil; n-- {
+			key := decodeCQLValue(t.elements[0], r.bytes())
+			result[fmt.Sprint(key)] = decodeCQLValue(t.elements[1], r.bytes())
+		}
+		return result
+	}
+	return hex.EncodeToString(value)
+}
+
+func decodeCQLInteger(value []byte) int64 {
+	var result int64
+	for i, b := range value {
+		if i == 0 {
+			result = int64(int8(b))
+			continue
+		}
+		result = result<<8 | int64(b)
+	}
+	return result
+}
+
+// cqlReader reads the protocol notations from a body. The first error is kept and the following reads are no-ops.
+type cqlReader struct {
+	buf []byte
+	err error
+}
+
+func (r *cqlReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = errCQLMalformed
+		return nil
+	}
+	result := r.buf[:n]
+	r.buf = r.buf[n:]
+	return result
+}
+
+func (r *cqlReader) int() int32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (r *cqlReader) short() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *cqlReader) string() string {
+	return string(r.next(int(r.short())))
+}
+
+// bytes returns nil for null values.
+func (r *cqlReader) bytes() []byte {
+	n := r.int()
+	if n < 0 {
+		return nil
+	}
+	return r.next(int(n))
+}
+
+func (r *cqlReader) option() cqlType {
+	t := cqlType{id: r.short()}
+	switch t.id {
+	case cqlTypeCustom:
+		r.string()
+	case cqlTypeList, cqlTypeSet:
+		t.elements = []cqlType{r.option()}
+	case cqlTypeMap:
+		t.elements = []cqlType{r.option(), r.option()}
+	case cqlTypeUDT:
+		r.string()
+		r.string()
+		for n := r.short(); n > 0 && r.err == nil; n-- {
+			r.string()
+			t.elements = append(t.elements, r.option())
+		}
+	case cqlTypeTuple:
+		for n := r.short(); n > 0 && r.err == nil; n-- {
+			t.elements = append(t.elements, r.option())
+		}
+	}
+	return t
+}
+
+func writeCQLStringMap(w *bytes.Buffer, values map[string]string) {
+	_ = binary.Write(w, binary.BigEndian, uint16(len(values)))
+	for key, value := range values {
+		writeCQLString(w, key)
+		writeCQLString(w, value)
+	}
+}
+
+func writeCQLString(w *bytes.Buffer, value string) {
+	_ = binary.Write(w, binary.BigEndian, uint16(len(value)))
+	w.WriteString(value)
+}
+
+func writeCQLLongString(w *bytes.Buffer, value string) {
+	_ = binary.Write(w, binary.BigEndian, uint32(len(value)))
+	w.WriteString(value)
+}
+
+func writeCQLBytes(w *bytes.Buffer, value []byte) {
+	_ = binary.Write(w, binary.BigEndian, uint32(len(value)))
+	w.Write(value)
+}
@@ -0,0 +1,407 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCQLColumn is a column of the rows served by fakeCQLServer.
+type fakeCQLColumn struct {
+	name   string
+	option []byte
+}
+
+// fakeCQLResult are the columns and rows returned for a statement.
+type fakeCQLResult struct {
+	columns []fakeCQLColumn
+	rows    [][][]byte
+}
+
+// fakeCQLServer is a stand-in for the Cassandra native transport, serving the results of each statement. Unknown
+// statements are answered with an invalid query error, like the tables missing in the version of the node.
+type fakeCQLServer struct {
+	listener net.Listener
+	username string
+	password string
+	results  map[string]fakeCQLResult
+	// queries are the statements received by the server.
+	queries chan string
+	// warnings are sent with the results of the queries, after a tracing id.
+	warnings []string
+	// streamShift is added to the stream id of the responses to the queries.
+	streamShift uint16
+}
+
+// newFakeCQLServer starts serving the results on a random local port. When username is set the clients have to
+// authenticate with the PasswordAuthenticator.
+func newFakeCQLServer(t *testing.T, username, password string, results map[string]fakeCQLResult) *fakeCQLServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeCQLServer{
+		listener: listener,
+		username: username,
+		password: password,
+		results:  results,
+		queries:  make(chan string, 100),
+	}
+	go server.serve()
+	return server
+}
+
+func (s *fakeCQLServer) config() cqlConfig {
+	return cqlConfig{
+		Address:  s.listener.Addr().String(),
+		Username: s.username,
+		Password: s.password,
+		Timeout:  time.Second,
+	}
+}
+
+func (s *fakeCQLServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeCQLServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, cqlHeaderLength)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[5:9]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+
+		opcode, response := s.respond(header[4], body)
+		frame := []byte{cqlResponseVersion, 0, header[2], header[3], opcode, 0, 0, 0, 0}
+		if header[4] == cqlOpQuery {
+			binary.BigEndian.PutUint16(frame[2:4], binary.BigEndian.Uint16(header[2:4])+s.streamShift)
+			if s.warnings != nil {
+				frame[1] = cqlHeaderFlagTracing | cqlHeaderFlagWarning
+				response = append(s.warningsPrefix(), response...)
+			}
+		}
+		binary.BigEndian.PutUint32(frame[5:9], uint32(len(response)))
+		if _, err := conn.Write(append(frame, response...)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeCQLServer) respond(opcode byte, body []byte) (byte, []byte) {
+	r := &cqlReader{buf: body}
+
+	switch opcode {
+	case cqlOpStartup:
+		if s.username != "" {
+			var response bytes.Buffer
+			writeCQLString(&response, "org.apache.cassandra.auth.PasswordAuthenticator")
+			return cqlOpAuthenticate, response.Bytes()
+		}
+		return cqlOpReady, nil
+	case cqlOpAuthResponse:
+		if string(r.bytes()) != "\x00"+s.username+"\x00"+s.password {
+			return cqlOpError, fakeCQLError(0x0100, "Provided username and/or password are incorrect")
+		}
+		return cqlOpAuthSuccess, []byte{0xFF, 0xFF, 0xFF, 0xFF}
+	case cqlOpQuery:
+		statement := string(r.next(int(r.int())))
+		s.queries <- statement
+		result, found := s.results[statement]
+		if !found {
+			return cqlOpError, fakeCQLError(0x2200, "table does not exist")
+		}
+
+		r.short() // Consistency.
+		flags := r.next(1)
+		if r.err != nil || flags[0]&cqlQueryFlagPageSize == 0 {
+			return cqlOpResult, result.encode()
+		}
+		// The paging state is the offset of the first row of the page.
+		offset, pageSize := 0, int(r.int())
+		if flags[0]&cqlQueryFlagWithPagingState != 0 {
+			offset = int(binary.BigEndian.Uint32(r.bytes()))
+		}
+		if offset+pageSize >= len(result.rows) {
+			return cqlOpResult, result.encodePage(result.rows[min(offset, len(result.rows)):], nil)
+		}
+		return cqlOpResult, result.encodePage(result.rows[offset:offset+pageSize], binary.BigEndian.AppendUint32(nil, uint32(offset+pageSize)))
+	}
+	return cqlOpError, fakeCQLError(0x000A, "unsupported opcode")
+}
+
+// warningsPrefix returns the tracing id and warnings preceding the body of the responses.
+func (s *fakeCQLServer) warningsPrefix() []byte {
+	prefix := bytes.NewBuffer(make([]byte, 16))
+	_ = binary.Write(prefix, binary.BigEndian, uint16(len(s.warnings)))
+	for _, warning := range s.warnings {
+		writeCQLString(prefix, warning)
+	}
+	return prefix.Bytes()
+}
+
+func (r fakeCQLResult) encode() []byte {
+	return r.encodePage(r.rows, nil)
+}
+
+// encodePage returns the RESULT body of the rows of a page, with the paging state of the next page if any.
+func (r fakeCQLResult) encodePage(rows [][][]byte, pagingState []byte) []byte {
+	flags := int32(cqlFlagGlobalTablesSpec)
+	if pagingState != nil {
+		flags |= cqlFlagHasMorePages
+	}
+
+	var response bytes.Buffer
+	_ = binary.Write(&response, binary.BigEndian, int32(cqlResultRows))
+	_ = binary.Write(&response, binary.BigEndian, flags)
+	_ = binary.Write(&response, binary.BigEndian, int32(len(r.columns)))
+	if pagingState != nil {
+		writeCQLBytes(&response, pagingState)
+	}
+	writeCQLString(&response, "system_views")
+	writeCQLString(&response, "settings")
+	for _, column := range r.columns {
+		writeCQLString(&response, column.name)
+		response.Write(column.option)
+	}
+
+	_ = binary.Write(&response, binary.BigEndian, int32(len(rows)))
+	for _, row := range rows {
+		for _, value := range row {
+			if value == nil {
+				_ = binary.Write(&response, binary.BigEndian, int32(-1))
+				continue
+			}
+			writeCQLBytes(&response, value)
+		}
+	}
+	return response.Bytes()
+}
+
+func fakeCQLError(code int32, message string) []byte {
+	var response bytes.Buffer
+	_ = binary.Write(&response, binary.BigEndian, code)
+	writeCQLString(&response, message)
+	return response.Bytes()
+}
+
+// cqlOption returns the serialized type of a column.
+func cqlOption(ids ...uint16) []byte {
+	var option bytes.Buffer
+	for _, id := range ids {
+		_ = binary.Write(&option, binary.BigEndian, id)
+	}
+	return option.Bytes()
+}
+
+// settingsColumns are the columns of the system_views.settings table.
+var settingsColumns = []fakeCQLColumn{
+	{name: "name", option: cqlOption(cqlTypeVarchar)},
+	{name: "value", option: cqlOption(cqlTypeVarchar)},
+}
+
+func TestCQLClient_Query(t *testing.T) {
+	server := newFakeCQLServer(t, "", "", map[string]fakeCQLResult{
+		systemViewsSettingsQuery: {columns: settingsColumns, rows: [][][]byte{
+			{[]byte("compaction_throughput_mb_per_sec"), []byte("64")},
+			{[]byte("ideal_consistency_level"), nil},
+		}},
+	})
+
+	client, err := newCQLClient(server.config())
+	require.NoError(t, err)
+	defer client.Close()
+
+	rows, err := client.Query(systemViewsSettingsQuery)
+	require.NoError(t, err)
+	assert.Equal(t, systemViewsSettingsQuery, <-server.queries)
+	assert.Equal(t, []map[string]interface{}{
+		{"name": "compaction_throughput_mb_per_sec", "value": "64"},
+		{"name": "ideal_consistency_level", "value": nil},
+	}, rows)
+
+	_, err = client.Query("SELECT * FROM system_views.unknown")
+	var cqlErr *CQLError
+	require.ErrorAs(t, err, &cqlErr)
+	assert.Equal(t, int32(0x2200), cqlErr.Code)
+}
+
+func TestCQLClient_QueryPages(t *testing.T) {
+	settings := fakeCQLResult{columns: settingsColumns}
+	for _, name := range []string{"cluster_name", "num_tokens", "concurrent_reads", "concurrent_writes", "auto_snapshot"} {
+		settings.rows = append(settings.rows, [][]byte{[]byte(name), []byte("value")})
+	}
+	server := newFakeCQLServer(t, "", "", map[string]fakeCQLResult{systemViewsSettingsQuery: settings})
+
+	client, err := newCQLClient(server.config())
+	require.NoError(t, err)
+	defer client.Close()
+	client.pageSize = 2
+
+	rows, err := client.Query(systemViewsSettingsQuery)
+	require.NoError(t, err)
+	require.Len(t, rows, 5, "the rows of the three pages are returned")
+	assert.Equal(t, "cluster_name", rows[0]["name"])
+	assert.Equal(t, "auto_snapshot", rows[4]["name"])
+	assert.Len(t, server.queries, 3)
+}
+
+func TestCQLClient_QueryWarnings(t *testing.T) {
+	server := newFakeCQLServer(t, "", "", map[string]fakeCQLResult{
+		systemViewsSettingsQuery: {columns: settingsColumns, rows: [][][]byte{{[]byte("cluster_name"), []byte("Test Cluster")}}},
+	})
+	server.warnings = []string{"Aggregation query used without partition key"}
+
+	client, err := newCQLClient(server.config())
+	require.NoError(t, err)
+	defer client.Close()
+
+	rows, err := client.Query(systemViewsSettingsQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"name": "cluster_name", "value": "Test Cluster"}}, rows)
+
+	_, err = client.Query("SELECT * FROM system_views.unknown")
+	var cqlErr *CQLError
+	require.ErrorAs(t, err, &cqlErr, "the error responses are read after the warnings")
+}
+
+func TestCQLClient_StreamMismatch(t *testing.T) {
+	server := newFakeCQLServer(t, "", "", map[string]fakeCQLResult{systemViewsSettingsQuery: {columns: settingsColumns}})
+	server.streamShift = 1
+
+	client, err := newCQLClient(server.config())
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Query(systemViewsSettingsQuery)
+	require.ErrorIs(t, err, errCQLUnexpectedResponse)
+	assert.True(t, client.closed, "the connection is closed after a failed request")
+
+	_, err = client.Query(systemViewsSettingsQuery)
+	assert.ErrorIs(t, err, net.ErrClosed)
+	assert.NoError(t, client.Close())
+}
+
+func TestCQLClient_Authentication(t *testing.T) {
+	server := newFakeCQLServer(t, "monitor", "s3cr3t", map[string]fakeCQLResult{
+		systemViewsSettingsQuery: {columns: settingsColumns},
+	})
+
+	client, err := newCQLClient(server.config())
+	require.NoError(t, err)
+	defer client.Close()
+
+	rows, err := client.Query(systemViewsSettingsQuery)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	config := server.config()
+	config.Password = "wrong"
+	_, err = newCQLClient(config)
+	assert.ErrorContains(t, err, "Provided username and/or password are incorrect")
+}
+
+func TestDecodeCQLValue(t *testing.T) {
+	int64Value := make([]byte, 8)
+	binary.BigEndian.PutUint64(int64Value, uint64(1640995200000))
+
+	var list bytes.Buffer
+	_ = binary.Write(&list, binary.BigEndian, int32(2))
+	writeCQLBytes(&list, []byte("dc1"))
+	writeCQLBytes(&list, []byte("dc2"))
+
+	var replication bytes.Buffer
+	_ = binary.Write(&replication, binary.BigEndian, int32(1))
+	writeCQLBytes(&replication, []byte("dc1"))
+	writeCQLBytes(&replication, []byte("3"))
+
+	testCases := []struct {
+		name     string
+		option   []byte
+		value    []byte
+		expected interface{}
+	}{
+		{"text", cqlOption(cqlTypeVarchar), []byte("LeveledCompactionStrategy"), "LeveledCompactionStrategy"},
+		{"int", cqlOption(cqlTypeInt), []byte{0x00, 0x0D, 0x2F, 0x00}, int64(864000)},
+		{"negative int", cqlOption(cqlTypeInt), []byte{0xFF, 0xFF, 0xFF, 0xFF}, int64(-1)},
+		{"boolean", cqlOption(cqlTypeBoolean), []byte{0x01}, true},
+		{"double", cqlOption(cqlTypeDouble), []byte{0x3F, 0x84, 0x7A, 0xE1, 0x47, 0xAE, 0x14, 0x7B}, 0.01},
+		{"timestamp", cqlOption(cqlTypeTimestamp), int64Value, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"inet", cqlOption(cqlTypeInet), []byte{10, 0, 0, 1}, "10.0.0.1"},
+		{"list", cqlOption(cqlTypeList, cqlTypeVarchar), list.Bytes(), []interface{}{"dc1", "dc2"}},
+		{"map", cqlOption(cqlTypeMap, cqlTypeVarchar, cqlTypeVarchar), replication.Bytes(), map[string]interface{}{"dc1": "3"}},
+		{"blob", cqlOption(cqlTypeBlob), []byte{0xCA, 0xFE}, "cafe"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &cqlReader{buf: tc.option}
+			assert.Equal(t, tc.expected, decodeCQLValue(r.option(), tc.value))
+			assert.NoError(t, r.err)
+		})
+	}
+}
+
+func TestParseCQLResult_Malformed(t *testing.T) {
+	result := fakeCQLResult{columns: settingsColumns, rows: [][][]byte{{[]byte("cluster_name"), []byte("Test Cluster")}}}.encode()
+
+	_, _, err := parseCQLResult(result[:len(result)-4])
+	assert.ErrorIs(t, err, errCQLMalformed)
+}
+
+func TestLazyCQLSource(t *testing.T) {
+	opened := 0
+	fake := &fakeCQLSource{results: map[string][]map[string]interface{}{"SELECT * FROM system.local": {{"key": "local"}}}}
+	source := &lazyCQLSource{open: func() (CQLSource, error) {
+		opened++
+		return fake, nil
+	}}
+
+	for range 2 {
+		rows, err := source.Query("SELECT * FROM system.local")
+		require.NoError(t, err)
+		assert.Equal(t, []map[string]interface{}{{"key": "local"}}, rows)
+	}
+	assert.Equal(t, 1, opened, "the connection is reused")
+
+	fake.err = &CQLError{Code: 0x2200, Message: "table pending_hints does not exist"}
+	_, err := source.Query("SELECT * FROM system_views.pending_hints")
+	require.Error(t, err)
+	assert.NotNil(t, source.client, "the connection is kept on CQL errors")
+
+	fake.err = io.EOF
+	_, err = source.Query("SELECT * FROM system.local")
+	require.Error(t, err)
+	assert.Nil(t, source.client, "the connection is dropped on other errors")
+
+	fake.err = nil
+	_, err = source.Query("SELECT * FROM system.local")
+	require.NoError(t, err)
+	assert.Equal(t, 2, opened)
+	require.NoError(t, source.Close())
+	assert.Nil(t, source.client)
+}
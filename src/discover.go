@@ -0,0 +1,313 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nrjmx/gojmx"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	discoverFormatTable = "table"
+	discoverFormatYAML  = "yaml"
+)
+
+// discoverDomains are the MBean patterns walked by the discover mode.
+var discoverDomains = []string{
+	"org.apache.cassandra.*:*",
+	"java.lang:*",
+}
+
+// discoveredAttribute is an MBean attribute found on the endpoint.
+type discoveredAttribute struct {
+	MBean     string
+	Attribute string
+	Type      string
+	Value     interface{}
+	// Pattern is the MBean name with the keyspace, table or thread pool replaced by wildcards, as used in the
+	// definitions.
+	Pattern string
+	// Section is the Definitions section where the pattern belongs.
+	Section string
+	Covered bool
+	// Readable is false when the value could not be retrieved.
+	Readable bool
+}
+
+// definitionPattern matches the MBean names covered by a definitions Query.
+type definitionPattern struct {
+	regex      *regexp.Regexp
+	attributes map[string]struct{}
+}
+
+type definitionPatterns []definitionPattern
+
+// runDiscover connects to the endpoint and prints the MBeans found, in the format set by args.DiscoverFormat.
+func runDiscover(w io.Writer) error {
+	if args.DiscoverFormat != discoverFormatTable && args.DiscoverFormat != discoverFormatYAML {
+		return fmt.Errorf("unsupported discover format: %q, valid values are: %q, %q", args.DiscoverFormat, discoverFormatTable, discoverFormatYAML)
+	}
+
+	definitions, err := collectionDefinitions()
+	if err != nil {
+		return err
+	}
+
+	client, err := openMBeanSource()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	attributes, err := discover(client, definitions)
+	if err != nil {
+		return err
+	}
+
+	if args.DiscoverFormat == discoverFormatYAML {
+		return printDefinitionsSkeleton(w, attributes)
+	}
+	return printDiscovered(w, attributes)
+}
+
+// discover walks the discoverDomains and returns all the attributes found, marking the ones already covered
+// by the definitions.
+func discover(client MBeanSource, definitions Definitions) ([]discoveredAttribute, error) {
+	patterns := newDefinitionPatterns(definitions)
+
+	var result []discoveredAttribute
+	for _, domain := range discoverDomains {
+		mBeanNames, err := client.QueryMBeanNames(domain)
+		if err != nil {
+			if jmxErr, ok := gojmx.IsJMXError(err); ok {
+				log.Debug("Failed to querying mBeanNames %s: %v", domain, jmxErr)
+				continue
+			}
+			return nil, fmt.Errorf("cannot retrieve mBeanNames for query: %q, error: %w", domain, err)
+		}
+
+		for _, mBeanName := range mBeanNames {
+			responses, err := client.QueryMBeanAttributes(mBeanName)
+			if err != nil {
+				if jmxErr, ok := gojmx.IsJMXError(err); ok {
+					log.Debug("Failed to get attributes for mBeanName %s: %v", mBeanName, jmxErr)
+					continue
+				}
+				return nil, fmt.Errorf("failed to perform query: %q, error: %w", mBeanName, err)
+			}
+
+			pattern, section := definitionSection(mBeanName)
+			for _, response := range responses {
+				attr := discoveredAttribute{
+					MBean:     mBeanName,
+					Attribute: attributeName(response.Name),
+					Type:      strings.ToLower(response.ResponseType.String()),
+					Value:     response.GetValue(),
+					Pattern:   pattern,
+					Section:   section,
+					Readable:  response.ResponseType != gojmx.ResponseTypeErr,
+				}
+				if !attr.Readable {
+					attr.Value = response.StatusMsg
+				}
+				attr.Covered = patterns.covers(mBeanName, attr.Attribute)
+
+				result = append(result, attr)
+			}
+		}
+	}
+	return result, nil
+}
+
+func newDefinitionPatterns(definitions Definitions) definitionPatterns {
+	var result definitionPatterns
+
+	groups := [][]Query{
+		definitions.Common,
+		definitions.Metrics,
+		definitions.ColumnFamilyMetrics,
+		definitions.ThreadPoolMetrics,
+		definitions.DroppedMessageMetrics,
+		definitions.HintsMetrics,
+	}
+	for _, queries := range groups {
+		for _, query := range queries {
+			expr := strings.ReplaceAll(regexp.QuoteMeta(query.MBean), `\*`, "[^,:]*")
+
+			pattern := definitionPattern{
+				regex:      regexp.MustCompile("^" + expr + "$"),
+				attributes: make(map[string]struct{}),
+			}
+			for _, attrName := range query.GetAttributeNames() {
+				pattern.attributes[attrName] = struct{}{}
+			}
+			result = append(result, pattern)
+		}
+	}
+	return result
+}
+
+// covers returns true if any definition collects the attribute of the MBean.
+func (p definitionPatterns) covers(mBeanName, attrName string) bool {
+	for _, pattern := range p {
+		if _, ok := pattern.attributes[attrName]; ok && pattern.regex.MatchString(mBeanName) {
+			return true
+		}
+	}
+	return false
+}
+
+// definitionSection returns the MBean pattern to be used in the definitions and the section where it belongs.
+// The names of per table, thread pool, dropped message and hints endpoint MBeans are turned into wildcard patterns.
+func definitionSection(mBeanName string) (string, string) {
+	if columnFamilyRegex.MatchString(mBeanName) {
+		pattern, _, _ := splitScopedName(mBeanName, columnFamilyRegex)
+		return pattern, "column_family_metrics"
+	}
+	if pattern, _, ok := splitScopedName(mBeanName, threadPoolRegex); ok {
+		return pattern, "thread_pool_metrics"
+	}
+	if pattern, _, ok := splitScopedName(mBeanName, droppedMessageRegex); ok {
+		return pattern, "dropped_message_metrics"
+	}
+	if pattern, _, ok := splitScopedName(mBeanName, hintsEndpointRegex); ok {
+		return pattern, "hints_metrics"
+	}
+	return mBeanName, "metrics"
+}
+
+// printDiscovered writes the discovered attributes as a table.
+func printDiscovered(w io.Writer, attributes []discoveredAttribute) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MBEAN\tATTRIBUTE\tTYPE\tVALUE\tCOVERED")
+
+	for _, attr := range attributes {
+		covered := "no"
+		if attr.Covered {
+			covered = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%v\t%s\n", attr.MBean, attr.Attribute, attr.Type, attr.Value, covered)
+	}
+	return tw.Flush()
+}
+
+// printDefinitionsSkeleton writes the readable attributes not covered by the definitions in the Definitions YAML
+// format. Numeric values are defined as gauges and the rest as attributes, and the aliases are derived from the
+// MBean name, so both should be reviewed before using them.
+func printDefinitionsSkeleton(w io.Writer, attributes []discoveredAttribute) error {
+	skeleton := Definitions{}
+	sections := map[string]*[]Query{
+		"metrics":                 &skeleton.Metrics,
+		"column_family_metrics":   &skeleton.ColumnFamilyMetrics,
+		"thread_pool_metrics":     &skeleton.ThreadPoolMetrics,
+		"dropped_message_metrics": &skeleton.DroppedMessageMetrics,
+		"hints_metrics":           &skeleton.HintsMetrics,
+	}
+
+	// Per table, thread pool, dropped message and hints endpoint MBeans are added once for all of their scopes.
+	queryIndex := make(map[string]int)
+	added := make(map[string]struct{})
+
+	for _, attr := range attributes {
+		if attr.Covered || !attr.Readable {
+			continue
+		}
+
+		key := attr.Pattern + "|" + attr.Attribute
+		if _, found := added[key]; found {
+			continue
+		}
+		added[key] = struct{}{}
+
+		metricType := metric.GAUGE
+		if attr.Type == "string" || attr.Type == "bool" {
+			metricType = metric.ATTRIBUTE
+		}
+
+		queries := sections[attr.Section]
+		idx, found := queryIndex[attr.Pattern]
+		if !found {
+			idx = len(*queries)
+			queryIndex[attr.Pattern] = idx
+			*queries = append(*queries, Query{MBean: attr.Pattern})
+		}
+		(*queries)[idx].Attributes = append((*queries)[idx].Attributes, Attribute{
+			MBeanAttribute: attr.Attribute,
+			Alias:          skeletonAlias(attr.Pattern, attr.Attribute),
+			MetricType:     metricType,
+		})
+	}
+
+	var doc yaml.Node
+	if err := doc.Encode(skeleton); err != nil {
+		return fmt.Errorf("failed to encode definitions skeleton: %w", err)
+	}
+	commentMetricTypes(&doc)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to write definitions skeleton: %w", err)
+	}
+	return encoder.Close()
+}
+
+// commentMetricTypes adds the name of the metric types, which are encoded as numbers.
+func commentMetricTypes(node *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i++ {
+		key, value := node.Content[i], node.Content[i+1]
+		if node.Kind == yaml.MappingNode && key.Value == "metric_type" {
+			var metricType metric.SourceType
+			if err := value.Decode(&metricType); err == nil {
+				value.LineComment = metricType.String()
+			}
+		}
+	}
+	for _, child := range node.Content {
+		commentMetricTypes(child)
+	}
+}
+
+// skeletonAlias derives a metric name from the MBean type, the rest of the MBean properties and the attribute,
+// e.g. 'org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Hits' 'Count' is named 'cache.keyCacheHits'.
+func skeletonAlias(mBeanPattern, attrName string) string {
+	_, props, _ := strings.Cut(mBeanPattern, ":")
+
+	prefix := ""
+	var name strings.Builder
+	for _, prop := range strings.Split(props, ",") {
+		key, value, _ := strings.Cut(prop, "=")
+		switch {
+		case value == "*" || value == "":
+			continue
+		case key == "type":
+			prefix = lowerFirst(value) + "."
+		default:
+			name.WriteString(value)
+		}
+	}
+
+	if attrName != "Value" && attrName != "Count" {
+		name.WriteString(attrName)
+	}
+	return prefix + lowerFirst(name.String())
+}
+
+func lowerFirst(value string) string {
+	for i, r := range value {
+		return string(unicode.ToLower(r)) + value[i+len(string(r)):]
+	}
+	return value
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDiscover(t *testing.T) {
+	attributes, err := discover(newFakeJMXClient(t, "cassandra.yml"), NewDefinitions())
+	require.NoError(t, err)
+
+	byName := make(map[string]discoveredAttribute)
+	for _, attr := range attributes {
+		byName[attr.MBean+"|"+attr.Attribute] = attr
+	}
+
+	covered := byName["org.apache.cassandra.metrics:type=Table,keyspace=users,scope=profiles,name=LiveSSTableCount|Value"]
+	assert.True(t, covered.Covered)
+	assert.Equal(t, "int", covered.Type)
+	assert.Equal(t, int64(4), covered.Value)
+
+	uncovered := byName["org.apache.cassandra.metrics:type=Table,keyspace=users,scope=profiles,name=BloomFilterDiskSpaceUsed|Value"]
+	assert.False(t, uncovered.Covered)
+	assert.Equal(t, "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=BloomFilterDiskSpaceUsed", uncovered.Pattern)
+	assert.Equal(t, "column_family_metrics", uncovered.Section)
+
+	jvm := byName["java.lang:type=Threading|ThreadCount"]
+	assert.False(t, jvm.Covered)
+	assert.Equal(t, "metrics", jvm.Section)
+
+	assert.True(t, byName["org.apache.cassandra.metrics:type=ThreadPools,path=request,scope=ReadStage,name=ActiveTasks|Value"].Covered)
+}
+
+func TestPrintDefinitionsSkeleton(t *testing.T) {
+	attributes, err := discover(newFakeJMXClient(t, "cassandra.yml"), NewDefinitions())
+	require.NoError(t, err)
+
+	var output bytes.Buffer
+	require.NoError(t, printDefinitionsSkeleton(&output, attributes))
+	assert.Contains(t, output.String(), "metric_type: 0 # gauge")
+
+	// The skeleton can be loaded as definitions.
+	var skeleton Definitions
+	require.NoError(t, yaml.Unmarshal(output.Bytes(), &skeleton))
+
+	assert.Empty(t, skeleton.Common)
+	assert.Equal(t, []Query{
+		{
+			MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=BloomFilterDiskSpaceUsed",
+			Attributes: []Attribute{
+				{MBeanAttribute: "Value", Alias: "table.bloomFilterDiskSpaceUsed", MetricType: metric.GAUGE},
+			},
+		},
+	}, skeleton.ColumnFamilyMetrics)
+
+	assert.Contains(t, skeleton.Metrics, Query{
+		MBean: "java.lang:type=Runtime",
+		Attributes: []Attribute{
+			{MBeanAttribute: "VmName", Alias: "runtime.vmName", MetricType: metric.ATTRIBUTE},
+		},
+	})
+	assert.Contains(t, skeleton.Metrics, Query{
+		MBean: "java.lang:type=Threading",
+		Attributes: []Attribute{
+			{MBeanAttribute: "ThreadCount", Alias: "threading.threadCount", MetricType: metric.GAUGE},
+		},
+	})
+}
+
+func TestPrintDiscovered(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, printDiscovered(&output, []discoveredAttribute{
+		{MBean: "java.lang:type=Threading", Attribute: "ThreadCount", Type: "int", Value: int64(42)},
+	}))
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, []string{"MBEAN", "ATTRIBUTE", "TYPE", "VALUE", "COVERED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"java.lang:type=Threading", "ThreadCount", "int", "42", "no"}, strings.Fields(lines[1]))
+}
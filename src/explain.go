@@ -0,0 +1,241 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/newrelic/nrjmx/gojmx"
+)
+
+const (
+	reasonSampleDisabled = "sample disabled"
+	reasonFiltered       = "filtered"
+	reasonSystemKeyspace = "system keyspace"
+	reasonLimitReached   = "limit reached"
+	reasonNotPresent     = "not present on this version"
+
+	// allSamples is the sample reported for the common definitions, which are added to every sample.
+	allSamples = "(all samples)"
+)
+
+// explainRow describes whether a metric definition is collected for a single MBean.
+type explainRow struct {
+	Sample     string
+	MBean      string
+	Attribute  string
+	Alias      string
+	MetricType string
+	// Checked is false when the attribute was not queried, since the MBean was already excluded.
+	Checked bool
+	Found   bool
+	// Reason is empty when the metric is collected.
+	Reason string
+}
+
+// explainGroup are the definitions reported on the same sample.
+type explainGroup struct {
+	sample       string
+	queries      []Query
+	enabled      bool
+	columnFamily bool
+}
+
+// runExplain connects to the endpoint and prints what would be collected with the current configuration.
+func runExplain(w io.Writer) error {
+	definitions, err := collectionDefinitions()
+	if err != nil {
+		return err
+	}
+
+	config, err := loadMetricsFilter(definitions)
+	if err != nil {
+		return err
+	}
+
+	client, err := openMBeanSource()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	rows, err := explain(client, definitions, config)
+	if err != nil {
+		return err
+	}
+	return printExplain(w, rows)
+}
+
+// explain expands the wildcards of the definitions the same way the collection does, and returns a row for each
+// MBean attribute with the reason it would be excluded from the collection.
+func explain(client MBeanSource, definitions Definitions, config FilteringConfig) ([]explainRow, error) {
+	groups := []explainGroup{
+		{sample: allSamples, queries: definitions.Common, enabled: true},
+		{sample: cassandraSample, queries: definitions.Metrics, enabled: true},
+		{sample: columnFamilySample, queries: definitions.ColumnFamilyMetrics, enabled: args.ColumnFamiliesLimit > 0, columnFamily: true},
+		{sample: threadPoolSample, queries: definitions.ThreadPoolMetrics, enabled: args.ThreadPoolMetrics},
+		{sample: droppedMessageSample, queries: definitions.DroppedMessageMetrics, enabled: args.DroppedMessageMetrics},
+		{sample: hintsSample, queries: definitions.HintsMetrics, enabled: args.HintsMetrics},
+	}
+
+	var rows []explainRow
+	for _, group := range groups {
+		groupRows, err := explainGroupRows(client, group, config)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, groupRows...)
+	}
+	return rows, nil
+}
+
+func explainGroupRows(client MBeanSource, group explainGroup, config FilteringConfig) ([]explainRow, error) {
+	var rows []explainRow
+	limiter := newColumnFamilyLimiter(args.ColumnFamiliesLimit)
+
+	for _, query := range group.queries {
+		scope := filterScope{Sample: group.sample, MBean: query.MBean}
+		if group.sample == allSamples {
+			scope.Sample = ""
+		}
+
+		mBeanNames, err := explainMBeanNames(client, query.MBean)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(mBeanNames) == 0 {
+			for _, attribute := range query.Attributes {
+				row := newExplainRow(group.sample, query.MBean, attribute)
+				row.Checked = true
+				row.Reason = explainReason(group, config, scope, attribute, reasonNotPresent)
+				rows = append(rows, row)
+			}
+			continue
+		}
+
+		for _, mBeanName := range mBeanNames {
+			exclusion := ""
+			if group.columnFamily {
+				exclusion = explainColumnFamily(limiter, mBeanName, query, config, &scope, group.enabled)
+			}
+
+			values := make(map[string]bool)
+			if exclusion == "" {
+				responses, err := client.GetMBeanAttributes(mBeanName, query.GetAttributeNames()...)
+				if err != nil {
+					if _, ok := gojmx.IsJMXError(err); !ok {
+						return nil, fmt.Errorf("failed to get attributes for mBeanName %q: %w", mBeanName, err)
+					}
+				}
+				for _, response := range responses {
+					if response.ResponseType != gojmx.ResponseTypeErr {
+						values[attributeName(response.Name)] = true
+					}
+				}
+			}
+
+			for _, attribute := range query.Attributes {
+				row := newExplainRow(group.sample, mBeanName, attribute)
+				row.Checked = exclusion == ""
+				row.Found = values[attribute.MBeanAttribute]
+
+				reason := exclusion
+				if row.Checked && !row.Found {
+					reason = reasonNotPresent
+				}
+				row.Reason = explainReason(group, config, scope, attribute, reason)
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// explainMBeanNames expands the wildcards of the MBean pattern. The names are empty when the MBean is not found.
+func explainMBeanNames(client MBeanSource, mBean string) ([]string, error) {
+	if !isMBeanPattern(mBean) {
+		return []string{mBean}, nil
+	}
+
+	mBeanNames, err := client.QueryMBeanNames(mBean)
+	if err != nil {
+		if _, ok := gojmx.IsJMXError(err); ok {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot retrieve mBeanNames for query: %q, error: %w", mBean, err)
+	}
+	return mBeanNames, nil
+}
+
+// explainColumnFamily sets the keyspace and table of the MBean in the scope, and returns the reason the column
+// family is excluded. Only the queries that are actually performed count towards the limit, as they do when
+// collecting.
+func explainColumnFamily(limiter *columnFamilyLimiter, mBeanName string, query Query, config FilteringConfig, scope *filterScope, enabled bool) string {
+	matches := columnFamilyRegex.FindStringSubmatch(mBeanName)
+	if matches == nil {
+		return ""
+	}
+
+	keyspace, columnFamily := matches[1], matches[2]
+	scope.Keyspace, scope.Table = keyspace, columnFamily
+
+	if !enabled || len(filterAttributes(query.Attributes, config, *scope)) == 0 {
+		if _, isFiltered := filteredKeyspace[keyspace]; isFiltered {
+			return reasonSystemKeyspace
+		}
+		return ""
+	}
+	return limiter.exclusion(keyspace, columnFamily)
+}
+
+// explainReason returns the first reason for the attribute to be excluded: a disabled sample, the filtering
+// configuration, and then the reason found while querying the node.
+func explainReason(group explainGroup, config FilteringConfig, scope filterScope, attribute Attribute, found string) string {
+	if !group.enabled {
+		return reasonSampleDisabled
+	}
+	if config.IsFiltered(scope, attribute) {
+		return reasonFiltered
+	}
+	return found
+}
+
+func newExplainRow(sample, mBean string, attribute Attribute) explainRow {
+	return explainRow{
+		Sample:     sample,
+		MBean:      mBean,
+		Attribute:  attribute.MBeanAttribute,
+		Alias:      attribute.Alias,
+		MetricType: attribute.MetricType.String(),
+	}
+}
+
+// printExplain writes the rows as a table.
+func printExplain(w io.Writer, rows []explainRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SAMPLE\tMBEAN\tATTRIBUTE\tALIAS\tTYPE\tFOUND\tEXCLUDED")
+
+	for _, row := range rows {
+		found := "-"
+		if row.Checked {
+			found = "no"
+			if row.Found {
+				found = "yes"
+			}
+		}
+
+		reason := row.Reason
+		if reason == "" {
+			reason = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", row.Sample, row.MBean, row.Attribute, row.Alias, row.MetricType, found, reason)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// explainRowsByAlias indexes the rows by '<mBean>|<alias>'.
+func explainRowsByAlias(rows []explainRow) map[string]explainRow {
+	result := make(map[string]explainRow)
+	for _, row := range rows {
+		result[row.MBean+"|"+row.Alias] = row
+	}
+	return result
+}
+
+func TestExplain(t *testing.T) {
+	setupCollection(t)
+	args.ColumnFamiliesLimit = 1
+	args.ThreadPoolMetrics = true
+
+	config, err := LoadFilteringConfig("exclude:\n  - query.viewWriteLatency50thPercentileMilliseconds\n  - db.keyCacheCapacityBytes")
+	require.NoError(t, err)
+
+	rows, err := explain(newFakeJMXClient(t, "cassandra.yml"), NewDefinitions(), config)
+	require.NoError(t, err)
+	byAlias := explainRowsByAlias(rows)
+
+	collected := byAlias["org.apache.cassandra.metrics:type=Client,name=connectedNativeClients|client.connectedNativeClients"]
+	assert.Equal(t, "CassandraSample", collected.Sample)
+	assert.Equal(t, "Value", collected.Attribute)
+	assert.Equal(t, "gauge", collected.MetricType)
+	assert.True(t, collected.Found)
+	assert.Empty(t, collected.Reason)
+
+	filtered := byAlias["org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Capacity|db.keyCacheCapacityBytes"]
+	assert.True(t, filtered.Found)
+	assert.Equal(t, reasonFiltered, filtered.Reason)
+
+	missing := byAlias["org.apache.cassandra.metrics:type=ClientRequest,scope=CASRead,name=Latency|query.CASReadRequestsPerSecond"]
+	assert.True(t, missing.Checked)
+	assert.False(t, missing.Found)
+	assert.Equal(t, reasonNotPresent, missing.Reason)
+
+	// Tables are limited in the same order as the collection: orders.items is the first one found.
+	table := "org.apache.cassandra.metrics:type=Table,keyspace=%s,scope=%s,name=LiveSSTableCount|db.liveSSTableCount"
+	assert.Empty(t, byAlias[fmt.Sprintf(table, "orders", "items")].Reason)
+	assert.Equal(t, reasonLimitReached, byAlias[fmt.Sprintf(table, "users", "profiles")].Reason)
+	assert.False(t, byAlias[fmt.Sprintf(table, "users", "profiles")].Checked)
+	assert.Equal(t, reasonSystemKeyspace, byAlias[fmt.Sprintf(table, "system", "local")].Reason)
+
+	threadPool := byAlias["org.apache.cassandra.metrics:type=ThreadPools,path=request,scope=ReadStage,name=ActiveTasks|activeTasks"]
+	assert.True(t, threadPool.Found)
+	assert.Empty(t, threadPool.Reason)
+
+	dropped := byAlias["org.apache.cassandra.metrics:type=DroppedMessage,scope=MUTATION,name=Dropped|droppedMessagesPerSecond"]
+	assert.True(t, dropped.Found)
+	assert.Equal(t, reasonSampleDisabled, dropped.Reason)
+}
+
+func TestExplain_MatchesCollection(t *testing.T) {
+	i := setupCollection(t)
+	args.ColumnFamiliesLimit = 2
+	fake := newFakeJMXClient(t, "cassandra.yml")
+
+	rows, err := explain(fake, NewDefinitions(), FilteringConfig{})
+	require.NoError(t, err)
+	require.NoError(t, collectMetrics(i, fake, NewDefinitions()))
+
+	explained := make(map[string]struct{})
+	for _, row := range rows {
+		matches := columnFamilyRegex.FindStringSubmatch(row.MBean)
+		if row.Sample == "CassandraColumnFamilySample" && row.Found && row.Reason == "" {
+			explained[matches[1]+"."+matches[2]] = struct{}{}
+		}
+	}
+
+	collected := make(map[string]struct{})
+	for _, sample := range samplesByEventType(i)["CassandraColumnFamilySample"] {
+		collected[sample["db.keyspaceAndColumnFamily"].(string)] = struct{}{}
+	}
+
+	assert.Equal(t, collected, explained)
+}
+
+func TestPrintExplain(t *testing.T) {
+	var output bytes.Buffer
+	require.NoError(t, printExplain(&output, []explainRow{
+		{Sample: "CassandraSample", MBean: "org.apache.cassandra.metrics:type=Storage,name=Load", Attribute: "Count", Alias: "db.loadBytes", MetricType: "gauge", Checked: true, Found: true},
+		{Sample: "CassandraColumnFamilySample", MBean: "org.apache.cassandra.metrics:type=Table,keyspace=system,scope=local,name=LiveSSTableCount", Attribute: "Value", Alias: "db.liveSSTableCount", MetricType: "gauge", Reason: reasonSystemKeyspace},
+	}))
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"SAMPLE", "MBEAN", "ATTRIBUTE", "ALIAS", "TYPE", "FOUND", "EXCLUDED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"CassandraSample", "org.apache.cassandra.metrics:type=Storage,name=Load", "Count", "db.loadBytes", "gauge", "yes", "-"}, strings.Fields(lines[1]))
+	assert.Equal(t, "system keyspace", lines[2][strings.Index(lines[0], "EXCLUDED"):])
+}
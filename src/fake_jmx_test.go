@@ -0,0 +1,252 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/nrjmx/gojmx"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// faultTimeout makes the query fail as nrjmx does when the request timeout is reached.
+	faultTimeout = "timeout"
+	// faultJMXError makes the query fail with a recoverable JMX error.
+	faultJMXError = "jmx_error"
+	// faultMissingAttributes makes all the attributes of the mBean to be reported with an error response.
+	faultMissingAttributes = "missing_attributes"
+	// faultClientError simulates the nrjmx sub-process being terminated.
+	faultClientError = "client_error"
+)
+
+// fakeJMXFixture is the YAML representation of the MBeans served by fakeJMXClient.
+type fakeJMXFixture struct {
+	MBeans map[string]map[string]interface{} `yaml:"mbeans"`
+	Faults []fakeJMXFault                    `yaml:"faults"`
+}
+
+// fakeJMXFault injects an error for all the queries matching the MBean pattern.
+type fakeJMXFault struct {
+	MBean string `yaml:"mbean"`
+	Type  string `yaml:"type"`
+}
+
+var _ MBeanSource = (*fakeJMXClient)(nil)
+
+// fakeJMXClient is an in-memory MBeanSource serving the MBeans from a fixture, used to test the collection
+// without the nrjmx sub-process and a running Cassandra.
+type fakeJMXClient struct {
+	mBeans  map[string]map[string]interface{}
+	faults  []fakeJMXFault
+	running bool
+}
+
+// newFakeJMXClient loads the fixture file from the testdata folder.
+func newFakeJMXClient(t *testing.T, fixture string) *fakeJMXClient {
+	t.Helper()
+
+	content, err := os.ReadFile(path.Join("testdata", fixture))
+	require.NoError(t, err)
+
+	var f fakeJMXFixture
+	require.NoError(t, yaml.Unmarshal(content, &f))
+
+	return &fakeJMXClient{
+		mBeans:  f.MBeans,
+		faults:  f.Faults,
+		running: true,
+	}
+}
+
+// inject adds a fault for the queries matching the mBean pattern.
+func (f *fakeJMXClient) inject(mBeanPattern, faultType string) {
+	f.faults = append(f.faults, fakeJMXFault{MBean: mBeanPattern, Type: faultType})
+}
+
+func (f *fakeJMXClient) QueryMBeanNames(mBeanGlobPattern string) ([]string, error) {
+	if err := f.fault(mBeanGlobPattern); err != nil {
+		return nil, err
+	}
+	return f.matchingNames(mBeanGlobPattern), nil
+}
+
+func (f *fakeJMXClient) QueryMBeanAttributes(mBeanNamePattern string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error) {
+	if err := f.fault(mBeanNamePattern); err != nil {
+		return nil, err
+	}
+
+	var result []*gojmx.AttributeResponse
+	for _, name := range f.matchingNames(mBeanNamePattern) {
+		result = append(result, f.attributes(name, mBeanAttrName)...)
+	}
+	return result, nil
+}
+
+func (f *fakeJMXClient) GetMBeanAttributes(mBeanName string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error) {
+	if err := f.fault(mBeanName); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.mBeans[mBeanName]; !ok {
+		return nil, &gojmx.JMXError{Message: fmt.Sprintf("instance not found: %s", mBeanName)}
+	}
+	return f.attributes(mBeanName, mBeanAttrName), nil
+}
+
+func (f *fakeJMXClient) IsRunning() bool {
+	return f.running
+}
+
+func (f *fakeJMXClient) Close() error {
+	f.running = false
+	return nil
+}
+
+// fault returns the error of the first injected fault matching the query, if any.
+func (f *fakeJMXClient) fault(query string) error {
+	for _, fault := range f.faults {
+		if fault.MBean != query && !matchMBeanName(fault.MBean, query) {
+			continue
+		}
+
+		switch fault.Type {
+		case faultTimeout:
+			return &gojmx.JMXConnectionError{Message: fmt.Sprintf("request timeout for: %s", query)}
+		case faultJMXError:
+			return &gojmx.JMXError{Message: fmt.Sprintf("injected error for: %s", query)}
+		case faultClientError:
+			f.running = false
+			return &gojmx.JMXClientError{Message: "nrjmx sub-process terminated"}
+		}
+	}
+	return nil
+}
+
+func (f *fakeJMXClient) isMissing(mBeanName string) bool {
+	for _, fault := range f.faults {
+		if fault.Type == faultMissingAttributes && matchMBeanName(fault.MBean, mBeanName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeJMXClient) matchingNames(pattern string) []string {
+	var result []string
+	for name := range f.mBeans {
+		if matchMBeanName(pattern, name) {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (f *fakeJMXClient) attributes(mBeanName string, attrNames []string) []*gojmx.AttributeResponse {
+	attrs := f.mBeans[mBeanName]
+	missing := f.isMissing(mBeanName)
+
+	if len(attrNames) == 0 {
+		for attrName := range attrs {
+			attrNames = append(attrNames, attrName)
+		}
+		sort.Strings(attrNames)
+	}
+
+	var result []*gojmx.AttributeResponse
+	for _, attrName := range attrNames {
+		response := &gojmx.AttributeResponse{Name: fmt.Sprintf("%s,attr=%s", mBeanName, attrName)}
+
+		value, ok := attrs[attrName]
+		if !ok || missing {
+			response.ResponseType = gojmx.ResponseTypeErr
+			response.StatusMsg = fmt.Sprintf("attribute not found: %s", attrName)
+			result = append(result, response)
+			continue
+		}
+
+		switch v := value.(type) {
+		case int:
+			response.ResponseType = gojmx.ResponseTypeInt
+			response.IntValue = int64(v)
+		case float64:
+			response.ResponseType = gojmx.ResponseTypeDouble
+			response.DoubleValue = v
+		case bool:
+			response.ResponseType = gojmx.ResponseTypeBool
+			response.BoolValue = v
+		case []interface{}:
+			// nrjmx cannot read array attributes, only the jolokia transport reports them.
+			response.ResponseType = gojmx.ResponseTypeErr
+			response.StatusMsg = fmt.Sprintf("unsupported array attribute: %s", attrName)
+		default:
+			response.ResponseType = gojmx.ResponseTypeString
+			response.StringValue = fmt.Sprintf("%v", v)
+		}
+		result = append(result, response)
+	}
+	return result
+}
+
+// matchMBeanName reports if the mBean name matches the JMX ObjectName pattern. Property values can contain
+// wildcards and a trailing '*' property allows the name to have extra properties.
+func matchMBeanName(pattern, name string) bool {
+	patternDomain, patternProps, ok := strings.Cut(pattern, ":")
+	if !ok {
+		return false
+	}
+	domain, props, ok := strings.Cut(name, ":")
+	if !ok {
+		return false
+	}
+
+	if !matchGlob(patternDomain, domain) {
+		return false
+	}
+
+	nameProps := make(map[string]string)
+	for _, prop := range strings.Split(props, ",") {
+		key, value, _ := strings.Cut(prop, "=")
+		nameProps[key] = value
+	}
+
+	isPropertyListPattern := false
+	matchedProps := 0
+	for _, prop := range strings.Split(patternProps, ",") {
+		if prop == "*" {
+			isPropertyListPattern = true
+			continue
+		}
+
+		key, patternValue, _ := strings.Cut(prop, "=")
+		value, found := nameProps[key]
+		if !found {
+			return false
+		}
+		if !matchGlob(patternValue, value) {
+			return false
+		}
+		matchedProps++
+	}
+
+	return isPropertyListPattern || matchedProps == len(nameProps)
+}
+
+// matchGlob matches the value against a JMX pattern supporting the '*' and '?' wildcards.
+func matchGlob(pattern, value string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("^" + expr + "$").MatchString(value)
+}
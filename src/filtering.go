@@ -5,20 +5,69 @@
 
 package main
 
-import "gopkg.in/yaml.v3"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Precedence of the filtering rules, the most specific rule matching a metric decides whether it is filtered.
+const (
+	precedenceNone = iota - 1
+	precedenceMetric
+	precedenceSample
+	precedenceMBean
+	precedenceTable
+)
 
 // FilteringConfig specifies which metrics should be included/excluded from reporting.
+// It has to be created with LoadFilteringConfig, which compiles the rule patterns.
 type FilteringConfig struct {
 	// Include will define which cassandra metric definitions will be included.
 	Include MetricNameList `yaml:"include"`
 	// Exclude specifies which nri-cassandra metric definitions will be excluded from collection.
 	Exclude MetricNameList `yaml:"exclude"`
+
+	include filterRules
+	exclude filterRules
 }
 
-// IsFiltered returns true if query is filtered by the configuration.
-// Include filters have precedence over Exclude filters.
-func (f FilteringConfig) IsFiltered(attribute Attribute) bool {
-	return f.Exclude.contains(attribute) && !f.Include.contains(attribute)
+// filterScope is where a metric is reported. Keyspace and Table are only known for the column family metrics
+// once the MBean wildcards are expanded.
+type filterScope struct {
+	Sample   string
+	MBean    string
+	Keyspace string
+	Table    string
+}
+
+// IsFiltered returns true if the attribute is filtered by the configuration in the given scope.
+// The most specific matching rule decides: keyspace or table rules first, then MBean, sample and metric name
+// only rules. Include rules have precedence over Exclude rules of the same level.
+func (f FilteringConfig) IsFiltered(scope filterScope, attribute Attribute) bool {
+	excluded := f.exclude.precedence(scope, attribute)
+	if excluded == precedenceNone {
+		return false
+	}
+	return f.include.precedence(scope, attribute) < excluded
+}
+
+// hasTableRules returns true if any rule is scoped to a keyspace or a table.
+func (f FilteringConfig) hasTableRules() bool {
+	for _, rules := range []filterRules{f.include, f.exclude} {
+		for _, rule := range rules {
+			if rule.level() == precedenceTable {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // LoadFilteringConfig unmarshal the YAML format filtering configuration.
@@ -29,19 +78,290 @@ func LoadFilteringConfig(cfg string) (FilteringConfig, error) {
 		return result, nil
 	}
 
-	err := yaml.Unmarshal([]byte(cfg), &result)
-	return result, err
+	// Unknown fields are rejected, so typos are not silently ignored.
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(cfg)))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(&result)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return result, err
+	}
+
+	if result.include, err = result.Include.compile(); err != nil {
+		return result, fmt.Errorf("invalid 'include' filter: %w", err)
+	}
+	if result.exclude, err = result.Exclude.compile(); err != nil {
+		return result, fmt.Errorf("invalid 'exclude' filter: %w", err)
+	}
+	return result, nil
 }
 
-// MetricNameList contains a list of metric names.
-type MetricNameList []string
+// MetricNameList contains a list of filtering rules.
+type MetricNameList []FilterRule
 
-// contains returns true if the Attribute Alias is found in the MetricNameList.
-func (f MetricNameList) contains(attribute Attribute) bool {
-	for _, metricName := range f {
-		if metricName == "*" || metricName == attribute.Alias {
-			return true
+// FilterRule matches the metrics by name, and optionally by the sample, the MBean of the definition or the
+// keyspace and table of column family metrics. A rule can be written as just the metric name.
+// Each field can be an exact value, a glob pattern where '*' matches any sequence of characters and '?' a single
+// one (e.g. 'db.threadpool.*'), or a regular expression when it starts with the '^' anchor
+// (e.g. '^query\..*999thPercentile.*$'). Empty fields match any value.
+type FilterRule struct {
+	Metric   string `yaml:"metric"`
+	Sample   string `yaml:"sample"`
+	MBean    string `yaml:"mbean"`
+	Keyspace string `yaml:"keyspace"`
+	Table    string `yaml:"table"`
+}
+
+// filterRuleFields are the fields accepted in a FilterRule.
+var filterRuleFields = []string{"metric", "sample", "mbean", "keyspace", "table"}
+
+// UnmarshalYAML accepts the metric name alone as a rule, and rejects unknown fields.
+func (r *FilterRule) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		r.Metric = value.Value
+		return nil
+	}
+
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i < len(value.Content); i += 2 {
+			key := value.Content[i]
+			if !slices.Contains(filterRuleFields, key.Value) {
+				return fmt.Errorf("line %d: field %s not found in filter rule, valid fields are: %s",
+					key.Line, key.Value, strings.Join(filterRuleFields, ", "))
+			}
 		}
 	}
-	return false
+
+	type plainRule FilterRule
+	return value.Decode((*plainRule)(r))
+}
+
+// String formats the rule as written in the configuration.
+func (r FilterRule) String() string {
+	if r == (FilterRule{Metric: r.Metric}) {
+		return fmt.Sprintf("%q", r.Metric)
+	}
+
+	var fields []string
+	for i, value := range []string{r.Metric, r.Sample, r.MBean, r.Keyspace, r.Table} {
+		if value != "" {
+			fields = append(fields, fmt.Sprintf("%s: %q", filterRuleFields[i], value))
+		}
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// filterRule is a compiled FilterRule, nil patterns match any value.
+type filterRule struct {
+	metric   *regexp.Regexp
+	sample   *regexp.Regexp
+	mBean    *regexp.Regexp
+	keyspace *regexp.Regexp
+	table    *regexp.Regexp
+}
+
+type filterRules []filterRule
+
+// compile turns the fields of each rule into regular expressions.
+func (f MetricNameList) compile() (filterRules, error) {
+	var result filterRules
+
+	for _, rule := range f {
+		var compiled filterRule
+		var err error
+
+		fields := []struct {
+			pattern string
+			regex   **regexp.Regexp
+		}{
+			{rule.Metric, &compiled.metric},
+			{rule.Sample, &compiled.sample},
+			{rule.MBean, &compiled.mBean},
+			{rule.Keyspace, &compiled.keyspace},
+			{rule.Table, &compiled.table},
+		}
+		for _, field := range fields {
+			if *field.regex, err = compilePattern(field.pattern); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, compiled)
+	}
+	return result, nil
+}
+
+// compilePattern turns an exact value, glob pattern or regular expression into a regular expression.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(pattern, "^") {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+		}
+		return regex, nil
+	}
+
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("^" + expr + "$"), nil
+}
+
+// precedence returns the level of the most specific rule matching the attribute, or precedenceNone.
+func (r filterRules) precedence(scope filterScope, attribute Attribute) int {
+	result := precedenceNone
+	for _, rule := range r {
+		if rule.matches(scope, attribute) && rule.level() > result {
+			result = rule.level()
+		}
+	}
+	return result
+}
+
+func (r filterRule) matches(scope filterScope, attribute Attribute) bool {
+	// Keyspace and table rules only apply to column family metrics.
+	if r.level() == precedenceTable && scope.Keyspace == "" {
+		return false
+	}
+
+	return matchPattern(r.metric, attribute.Alias) &&
+		matchPattern(r.sample, scope.Sample) &&
+		matchPattern(r.mBean, scope.MBean) &&
+		matchPattern(r.keyspace, scope.Keyspace) &&
+		matchPattern(r.table, scope.Table)
+}
+
+func (r filterRule) level() int {
+	switch {
+	case r.keyspace != nil || r.table != nil:
+		return precedenceTable
+	case r.mBean != nil:
+		return precedenceMBean
+	case r.sample != nil:
+		return precedenceSample
+	default:
+		return precedenceMetric
+	}
+}
+
+// matchPattern returns true for nil patterns, otherwise the value has to be matched.
+func matchPattern(pattern *regexp.Regexp, value string) bool {
+	return pattern == nil || pattern.MatchString(value)
+}
+
+// Validate returns a warning for each rule that matches no metric of the definitions, suggesting the closest
+// metric or sample name when the rule has a typo.
+func (f FilteringConfig) Validate(definitions Definitions) []string {
+	groups := []struct {
+		sample  string
+		queries []Query
+	}{
+		{"", definitions.Common},
+		{cassandraSample, definitions.Metrics},
+		{columnFamilySample, definitions.ColumnFamilyMetrics},
+		{threadPoolSample, definitions.ThreadPoolMetrics},
+		{droppedMessageSample, definitions.DroppedMessageMetrics},
+		{hintsSample, definitions.HintsMetrics},
+	}
+
+	var aliases, samples []string
+	for _, group := range groups {
+		if group.sample != "" {
+			samples = append(samples, group.sample)
+		}
+		for _, query := range group.queries {
+			for _, attribute := range query.Attributes {
+				aliases = append(aliases, attribute.Alias)
+			}
+		}
+	}
+
+	lists := []struct {
+		name     string
+		rules    MetricNameList
+		compiled filterRules
+	}{
+		{"include", f.Include, f.include},
+		{"exclude", f.Exclude, f.exclude},
+	}
+
+	var warnings []string
+	for _, list := range lists {
+		for i, rule := range list.compiled {
+			// Keyspaces and tables are only known when collecting, so any column family metric can match them.
+			known := rule
+			known.keyspace, known.table = nil, nil
+
+			found := false
+			for _, group := range groups {
+				if rule.level() == precedenceTable && group.sample != columnFamilySample {
+					continue
+				}
+				for _, query := range group.queries {
+					for _, attribute := range query.Attributes {
+						found = found || known.matches(filterScope{Sample: group.sample, MBean: query.MBean}, attribute)
+					}
+				}
+			}
+			if found {
+				continue
+			}
+
+			warning := fmt.Sprintf("metrics filter '%s' rule %s matches no known metric", list.name, list.rules[i])
+			if suggestion := closest(list.rules[i].Metric, aliases, rule.metric); suggestion != "" {
+				warning += fmt.Sprintf(", did you mean %q?", suggestion)
+			} else if suggestion := closest(list.rules[i].Sample, samples, rule.sample); suggestion != "" {
+				warning += fmt.Sprintf(", did you mean sample %q?", suggestion)
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// closest returns the candidate with the smallest edit distance to the value, when no candidate is matched by
+// the pattern and the distance is small enough to be a typo.
+func closest(value string, candidates []string, pattern *regexp.Regexp) string {
+	if pattern == nil {
+		return ""
+	}
+	for _, candidate := range candidates {
+		if pattern.MatchString(candidate) {
+			return ""
+		}
+	}
+
+	result := ""
+	maxDistance := len(value)/3 + 1
+	for _, candidate := range candidates {
+		if distance := editDistance(strings.ToLower(value), strings.ToLower(candidate)); distance <= maxDistance {
+			result, maxDistance = candidate, distance-1
+		}
+	}
+	return result
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
 }
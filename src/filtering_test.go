@@ -39,9 +39,12 @@ func TestWithoutFilteringConfig(t *testing.T) {
 	definitions.Filter(config)
 
 	expected := Definitions{
-		Common:              commonDefinitions,
-		Metrics:             metricDefinitions,
-		ColumnFamilyMetrics: columnFamilyDefinitions,
+		Common:                commonDefinitions,
+		Metrics:               metricDefinitions,
+		ColumnFamilyMetrics:   columnFamilyDefinitions,
+		ThreadPoolMetrics:     threadPoolDefinitions,
+		DroppedMessageMetrics: droppedMessageDefinitions,
+		HintsMetrics:          hintsDefinitions,
 	}
 	assert.Equal(t, expected, definitions)
 }
@@ -61,9 +64,12 @@ include:
 	definitions.Filter(config)
 
 	expected := Definitions{
-		Common:              commonDefinitions,
-		Metrics:             metricDefinitions,
-		ColumnFamilyMetrics: columnFamilyDefinitions,
+		Common:                commonDefinitions,
+		Metrics:               metricDefinitions,
+		ColumnFamilyMetrics:   columnFamilyDefinitions,
+		ThreadPoolMetrics:     threadPoolDefinitions,
+		DroppedMessageMetrics: droppedMessageDefinitions,
+		HintsMetrics:          hintsDefinitions,
 	}
 	assert.Equal(t, expected, definitions)
 }
@@ -103,10 +109,196 @@ include:
 			{
 				MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=TombstoneScannedHistogram",
 				Attributes: []Attribute{
-					{MBeanAttribute: "999thPercentile", Alias: "db.tombstoneScannedHistogram999thPercentile", MetricType: metric.GAUGE},
+					{MBeanAttribute: "999thPercentile", Alias: "db.tombstoneScannedHistogram999thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
 				},
 			},
 		},
 	}
 	assert.Equal(t, expected, definitions)
 }
+
+func TestExcludeGlob(t *testing.T) {
+	configYAML := `
+exclude:
+  - "db.threadpool.*"
+  - "*999thPercentile*"
+include:
+  - db.threadpool.requestReadStage?ctiveTasks
+`
+
+	config, err := LoadFilteringConfig(configYAML)
+	assert.NoError(t, err)
+
+	assert.True(t, config.IsFiltered(filterScope{}, Attribute{Alias: "db.threadpool.internalCompactionExecutorActiveTasks"}))
+	assert.True(t, config.IsFiltered(filterScope{}, Attribute{Alias: "query.readLatency999thPercentileMilliseconds"}))
+	assert.False(t, config.IsFiltered(filterScope{}, Attribute{Alias: "db.threadpool.requestReadStageActiveTasks"}))
+	assert.False(t, config.IsFiltered(filterScope{}, Attribute{Alias: "query.readLatency99thPercentileMilliseconds"}))
+	assert.False(t, config.IsFiltered(filterScope{}, Attribute{Alias: "db.threadpoolTotal"}))
+}
+
+func TestExcludeRegex(t *testing.T) {
+	configYAML := `
+exclude:
+  - '^query\.(read|write)Latency(50|75)thPercentileMilliseconds$'
+`
+
+	config, err := LoadFilteringConfig(configYAML)
+	assert.NoError(t, err)
+
+	assert.True(t, config.IsFiltered(filterScope{}, Attribute{Alias: "query.readLatency50thPercentileMilliseconds"}))
+	assert.True(t, config.IsFiltered(filterScope{}, Attribute{Alias: "query.writeLatency75thPercentileMilliseconds"}))
+	assert.False(t, config.IsFiltered(filterScope{}, Attribute{Alias: "query.readLatency99thPercentileMilliseconds"}))
+	assert.False(t, config.IsFiltered(filterScope{}, Attribute{Alias: "query.viewWriteLatency50thPercentileMilliseconds"}))
+}
+
+func TestExactNameIsNotAPattern(t *testing.T) {
+	config, err := LoadFilteringConfig("exclude:\n  - db.loadBytes")
+	assert.NoError(t, err)
+
+	assert.True(t, config.IsFiltered(filterScope{}, Attribute{Alias: "db.loadBytes"}))
+	assert.False(t, config.IsFiltered(filterScope{}, Attribute{Alias: "dbXloadBytes"}))
+}
+
+func TestInvalidRegex(t *testing.T) {
+	_, err := LoadFilteringConfig("include:\n  - '^query\\.(read'")
+	assert.EqualError(t, err, "invalid 'include' filter: invalid regular expression \"^query\\\\.(read\": error parsing regexp: missing closing ): `^query\\.(read`")
+}
+
+func TestFilterBySample(t *testing.T) {
+	configYAML := `
+exclude:
+  - metric: query.readLatency99thPercentileMilliseconds
+    sample: CassandraColumnFamilySample
+`
+
+	config, err := LoadFilteringConfig(configYAML)
+	assert.NoError(t, err)
+
+	definitions := NewDefinitions()
+	definitions.Filter(config)
+
+	aliases := func(queries []Query) map[string]bool {
+		result := make(map[string]bool)
+		for _, query := range queries {
+			for _, attribute := range query.Attributes {
+				result[attribute.Alias] = true
+			}
+		}
+		return result
+	}
+
+	assert.True(t, aliases(definitions.Metrics)["query.readLatency99thPercentileMilliseconds"])
+	assert.False(t, aliases(definitions.ColumnFamilyMetrics)["query.readLatency99thPercentileMilliseconds"])
+	assert.True(t, aliases(definitions.ColumnFamilyMetrics)["query.readLatency98thPercentileMilliseconds"])
+}
+
+func TestFilterByMBean(t *testing.T) {
+	configYAML := `
+exclude:
+  - mbean: "org.apache.cassandra.metrics:type=ThreadPools,*"
+`
+
+	config, err := LoadFilteringConfig(configYAML)
+	assert.NoError(t, err)
+
+	definitions := NewDefinitions()
+	definitions.Filter(config)
+
+	assert.Empty(t, definitions.ThreadPoolMetrics)
+	for _, query := range definitions.Metrics {
+		assert.NotContains(t, query.MBean, "type=ThreadPools")
+	}
+	assert.Equal(t, commonDefinitions, definitions.Common)
+}
+
+func TestFilterPrecedence(t *testing.T) {
+	configYAML := `
+exclude:
+  - "*"
+  - metric: db.loadBytes
+    sample: CassandraSample
+  - metric: db.liveSSTableCount
+    keyspace: users
+include:
+  - "*"
+  - metric: client.connectedNativeClients
+    sample: CassandraSample
+  - mbean: "org.apache.cassandra.metrics:type=Storage,*"
+  - metric: db.liveSSTableCount
+    keyspace: users
+    table: profiles
+`
+
+	config, err := LoadFilteringConfig(configYAML)
+	assert.NoError(t, err)
+
+	node := filterScope{Sample: "CassandraSample", MBean: "org.apache.cassandra.metrics:type=Client,name=connectedNativeClients"}
+	storage := filterScope{Sample: "CassandraSample", MBean: "org.apache.cassandra.metrics:type=Storage,name=Load"}
+	table := filterScope{Sample: "CassandraColumnFamilySample", MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=LiveSSTableCount"}
+
+	// Include has precedence over exclude on the same level.
+	assert.False(t, config.IsFiltered(node, Attribute{Alias: "client.connectedNativeClients"}))
+	// Sample rules have precedence over the metric name only rules, and MBean rules over sample rules.
+	assert.True(t, config.IsFiltered(node, Attribute{Alias: "db.loadBytes"}))
+	assert.False(t, config.IsFiltered(storage, Attribute{Alias: "db.loadBytes"}))
+
+	// Keyspace and table rules have the highest precedence, and only apply to column families.
+	table.Keyspace, table.Table = "users", "profiles"
+	assert.False(t, config.IsFiltered(table, Attribute{Alias: "db.liveSSTableCount"}))
+	table.Table = "sessions"
+	assert.True(t, config.IsFiltered(table, Attribute{Alias: "db.liveSSTableCount"}))
+	assert.False(t, config.IsFiltered(filterScope{Sample: "CassandraSample"}, Attribute{Alias: "db.liveSSTableCount"}))
+}
+
+func TestFilterWithTableRules(t *testing.T) {
+	config, err := LoadFilteringConfig("exclude:\n  - table: sessions")
+	assert.NoError(t, err)
+
+	definitions := NewDefinitions()
+	definitions.Filter(config)
+
+	// Column family metrics are filtered for each table when collecting.
+	assert.Equal(t, columnFamilyDefinitions, definitions.ColumnFamilyMetrics)
+	assert.NotNil(t, definitions.columnFamilyFilter)
+}
+
+func TestLoadFilteringConfig_UnknownFields(t *testing.T) {
+	_, err := LoadFilteringConfig("exlude:\n  - db.loadBytes")
+	assert.ErrorContains(t, err, "field exlude not found")
+
+	_, err = LoadFilteringConfig("exclude:\n  - metric: db.loadBytes\n    keyspaces: users")
+	assert.EqualError(t, err, "line 3: field keyspaces not found in filter rule, valid fields are: metric, sample, mbean, keyspace, table")
+
+	config, err := LoadFilteringConfig("\n")
+	assert.NoError(t, err)
+	assert.Empty(t, config.Exclude)
+}
+
+func TestValidate(t *testing.T) {
+	configYAML := `
+exclude:
+  - "*"
+  - db.liveSSTabelCount
+  - "db.threadpol.*"
+  - metric: db.loadBytes
+    sample: CassandraSampel
+  - metric: db.loadBytes
+    table: profiles
+  - metric: db.liveSSTableCount
+    keyspace: users
+include:
+  - mbean: "org.apache.cassandra.metrics:type=ThreadPools,*"
+    sample: CassandraColumnFamilySample
+`
+
+	config, err := LoadFilteringConfig(configYAML)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`metrics filter 'include' rule {sample: "CassandraColumnFamilySample", mbean: "org.apache.cassandra.metrics:type=ThreadPools,*"} matches no known metric`,
+		`metrics filter 'exclude' rule "db.liveSSTabelCount" matches no known metric, did you mean "db.liveSSTableCount"?`,
+		`metrics filter 'exclude' rule "db.threadpol.*" matches no known metric`,
+		`metrics filter 'exclude' rule {metric: "db.loadBytes", sample: "CassandraSampel"} matches no known metric, did you mean sample "CassandraSample"?`,
+		`metrics filter 'exclude' rule {metric: "db.loadBytes", table: "profiles"} matches no known metric`,
+	}, config.Validate(NewDefinitions()))
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+// pendingHintsTable lists the hints files waiting to be delivered to each endpoint, since Cassandra 4.1.
+const pendingHintsTable = "system_views.pending_hints"
+
+// pendingHintsSource is the connection to the native transport used to read the pending hints, opened on the first
+// collection and reused by the next ones.
+var pendingHintsSource = &lazyCQLSource{open: openCQLSource}
+
+// hintsAddressPortRegex matches the IPv4 address and port of the endpoints in the hints MBean names since Cassandra
+// 4.0, where the colon before the port is replaced by a dot, e.g. '127.0.0.2.7000'.
+var hintsAddressPortRegex = regexp.MustCompile(`^(\d+\.\d+\.\d+\.\d+)\.(\d+)$`)
+
+// hintsIPv6AddressPortRegex matches the bracketed IPv6 address and port of the endpoints in the hints MBean names,
+// where every colon is replaced by a dot, e.g. '[0.0.0.0.0.0.0.1].7000'.
+var hintsIPv6AddressPortRegex = regexp.MustCompile(`^\[/?([0-9a-fA-F.]+)\]\.(\d+)$`)
+
+// getHintsMetrics returns the metrics of the per endpoint hints MBeans keyed by endpoint, with the pending hints
+// files of each endpoint read from the system_views.pending_hints table when SYSTEM_VIEWS is enabled. The table is
+// available since Cassandra 4.1, and its total_size column since 5.0.
+func getHintsMetrics(jmxClient MBeanSource, queries []Query) (map[string]map[string]interface{}, error) {
+	allEndpoints, err := getScopedMetrics(jmxClient, queries, hintsEndpointRegex, "endpoint")
+	if err != nil {
+		return nil, err
+	}
+
+	hints := make(map[string]map[string]interface{}, len(allEndpoints))
+	for _, metrics := range allEndpoints {
+		endpoint := hintsEndpoint(metrics["endpoint"].(string))
+		if _, found := hints[endpoint]; !found {
+			hints[endpoint] = make(map[string]interface{})
+		}
+		for key, value := range metrics {
+			hints[endpoint][key] = value
+		}
+		hints[endpoint]["endpoint"] = endpoint
+	}
+
+	if !args.SystemViews {
+		return hints, nil
+	}
+
+	reader := &systemViewsReader{client: pendingHintsSource, rows: make(map[string][]map[string]interface{})}
+	rows, err := reader.read(pendingHintsTable)
+	if err != nil {
+		log.Warn("Failed to read the pending hints files from the native transport: %v", err)
+		return hints, nil
+	}
+	addPendingHints(hints, rows)
+	return hints, nil
+}
+
+// hintsEndpoint returns the endpoint of the hints MBean names as reported by the samples. Since Cassandra 4.0 the
+// names have the address and the port, with the colons replaced by dots, e.g. '/127.0.0.2.7000' is reported as
+// '127.0.0.2:7000', and '/[0.0.0.0.0.0.0.1].7000' as '[::1]:7000', the address of the pending_hints table. Before,
+// they only have the address. The names in other forms, e.g. IPv6 addresses without brackets where the port can't
+// be told apart, are reported as is.
+func hintsEndpoint(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if match := hintsAddressPortRegex.FindStringSubmatch(name); match != nil {
+		return net.JoinHostPort(match[1], match[2])
+	}
+	if match := hintsIPv6AddressPortRegex.FindStringSubmatch(name); match != nil {
+		if ip := net.ParseIP(strings.ReplaceAll(match[1], ".", ":")); ip != nil {
+			return net.JoinHostPort(ip.String(), match[2])
+		}
+	}
+	return name
+}
+
+// addPendingHints adds the columns of the system_views.pending_hints rows to the hints of their endpoint. The
+// endpoints without hints metrics, e.g. with files left from a previous run, are added too.
+func addPendingHints(hints map[string]map[string]interface{}, rows []map[string]interface{}) {
+	for _, row := range rows {
+		address, ok := row["address"].(string)
+		if !ok {
+			continue
+		}
+		endpoint := address
+		if port, ok := row["port"].(int64); ok {
+			endpoint = net.JoinHostPort(address, strconv.FormatInt(port, 10))
+		}
+
+		metrics, found := hints[endpoint]
+		if !found {
+			metrics = map[string]interface{}{"endpoint": endpoint}
+			hints[endpoint] = metrics
+		}
+		for column, value := range row {
+			if value != nil {
+				metrics[column] = value
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pendingHintsResult is the system_views.pending_hints table of a Cassandra 5.0 node with hints for two endpoints.
+func pendingHintsResult() fakeCQLResult {
+	types := map[string]uint16{
+		"host_id": cqlTypeUUID, "address": cqlTypeInet, "port": cqlTypeInt, "dc": cqlTypeVarchar, "rack": cqlTypeVarchar,
+		"status": cqlTypeVarchar, "files": cqlTypeInt, "total_size": cqlTypeBigint,
+	}
+	hostID := []byte{0x5b, 0x4c, 0x1e, 0x7c, 0x1f, 0x5a, 0x4a, 0x5d, 0x9c, 0x7e, 0x2f, 0x1d, 0x6c, 0x0a, 0x9b, 0x3e}
+	row := func(address string, files int32, totalSize int64, status string) [][]byte {
+		return [][]byte{
+			hostID, net.ParseIP(address).To4(), binary.BigEndian.AppendUint32(nil, 7000), cqlText("dc1"), cqlText("rack1"),
+			cqlText(status), binary.BigEndian.AppendUint32(nil, uint32(files)), cqlBigint(totalSize),
+		}
+	}
+	return fakeCQLResult{
+		columns: cqlColumns(types, "host_id", "address", "port", "dc", "rack", "status", "files", "total_size"),
+		rows:    [][][]byte{row("127.0.0.2", 3, 1048576, "DOWN"), row("127.0.0.3", 1, 2048, "NORMAL")},
+	}
+}
+
+func TestHintsEndpoint(t *testing.T) {
+	testCases := map[string]string{
+		"127.0.0.2":                             "127.0.0.2",
+		"/127.0.0.2.7000":                       "127.0.0.2:7000",
+		"/0.0.0.0.0.0.0.1.7000":                 "0.0.0.0.0.0.0.1.7000",
+		"fe80.0.0.0.202.b3ff.fe1e.8329":         "fe80.0.0.0.202.b3ff.fe1e.8329",
+		"/10.100.200.30.7001":                   "10.100.200.30:7001",
+		"cassandra-2.cassandra.svc.7000":        "cassandra-2.cassandra.svc.7000",
+		"/[0.0.0.0.0.0.0.1].7000":               "[::1]:7000",
+		"[/fe80.0.0.0.202.b3ff.fe1e.8329].7000": "[fe80::202:b3ff:fe1e:8329]:7000",
+		"[fe80.0.0.0.202.b3ff].7000":            "[fe80.0.0.0.202.b3ff].7000",
+	}
+
+	for name, expected := range testCases {
+		assert.Equal(t, expected, hintsEndpoint(name), name)
+	}
+}
+
+func TestCollectMetrics_Hints(t *testing.T) {
+	i := setupCollection(t)
+	args.HintsMetrics = true
+	client := newFakeJMXClient(t, "cassandra.yml")
+	client.mBeans["org.apache.cassandra.metrics:type=HintedHandOffManager,name=Hints_created-/127.0.0.2.7000"] = map[string]interface{}{"Count": 120}
+	client.mBeans["org.apache.cassandra.metrics:type=HintedHandOffManager,name=Hints_created-/127.0.0.3.7000"] = map[string]interface{}{"Count": 5}
+	client.mBeans["org.apache.cassandra.metrics:type=HintsService,name=Hint_delays-/127.0.0.3.7000"] = map[string]interface{}{"Count": 5, "99thPercentile": 35.0}
+	client.mBeans["org.apache.cassandra.metrics:type=HintsService,name=Hint_delays"] = map[string]interface{}{"Count": 5, "99thPercentile": 35.0}
+
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+
+	samples := samplesByEventType(i)[hintsSample]
+	require.Len(t, samples, 2, "the node level delays are not reported as an endpoint")
+	assert.Equal(t, "127.0.0.2:7000", samples[0]["endpoint"])
+	assert.Equal(t, 0.0, samples[0]["hints.createdPerSecond"])
+	assert.NotContains(t, samples[0], "hints.succeededPerSecond")
+	assert.Equal(t, "127.0.0.3:7000", samples[1]["endpoint"])
+	assert.Equal(t, 0.0, samples[1]["hints.succeededPerSecond"])
+	assert.Equal(t, 35.0, samples[1]["hints.delay99thPercentileMilliseconds"])
+	assert.Equal(t, "4.0.3", samples[1]["software.version"])
+	assert.NotContains(t, samples[1], "hints.pendingFiles")
+}
+
+func TestCollectMetrics_HintsPendingFiles(t *testing.T) {
+	i := setupCollection(t)
+	args.HintsMetrics = true
+	args.SystemViews = true
+	server := newFakeCQLServer(t, "", "", map[string]fakeCQLResult{"SELECT * FROM " + pendingHintsTable: pendingHintsResult()})
+	args.CQLHostname, args.CQLPort = splitHostPort(t, server.listener.Addr().String())
+	args.Timeout = 1000
+	client := newFakeJMXClient(t, "cassandra.yml")
+	client.mBeans["org.apache.cassandra.metrics:type=HintedHandOffManager,name=Hints_created-/127.0.0.2.7000"] = map[string]interface{}{"Count": 120}
+
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+
+	samples := samplesByEventType(i)[hintsSample]
+	require.Len(t, samples, 2)
+	assert.Equal(t, "127.0.0.2:7000", samples[0]["endpoint"])
+	assert.Equal(t, 0.0, samples[0]["hints.createdPerSecond"])
+	assert.Equal(t, 3.0, samples[0]["hints.pendingFiles"])
+	assert.Equal(t, 1048576.0, samples[0]["hints.pendingFilesSizeBytes"])
+	assert.Equal(t, "DOWN", samples[0]["endpoint.status"])
+	assert.Equal(t, "dc1", samples[0]["endpoint.datacenter"])
+	assert.Equal(t, "5b4c1e7c-1f5a-4a5d-9c7e-2f1d6c0a9b3e", samples[0]["endpoint.hostId"])
+	assert.Equal(t, "127.0.0.3:7000", samples[1]["endpoint"], "the endpoints with only pending files are reported")
+	assert.Equal(t, 2048.0, samples[1]["hints.pendingFilesSizeBytes"])
+	assert.NotContains(t, samples[1], "hints.createdPerSecond")
+
+	cqlClient := pendingHintsSource.client
+	require.NotNil(t, cqlClient)
+	require.NoError(t, collectMetrics(i, client, NewDefinitions()))
+	assert.Same(t, cqlClient, pendingHintsSource.client, "the connection is reused by the next collection")
+}
+
+func TestCollectSystemViewsMetrics_Hints(t *testing.T) {
+	i := setupCollection(t)
+	args.HintsMetrics = true
+	server := setupSystemViewsCollection(t)
+	server.results["SELECT * FROM "+pendingHintsTable] = pendingHintsResult()
+	cqlClient, err := openCQLSource()
+	require.NoError(t, err)
+	defer cqlClient.Close()
+
+	require.NoError(t, collectSystemViewsMetrics(i, cqlClient, NewSystemViewsDefinitions()))
+
+	samples := samplesByEventType(i)[hintsSample]
+	require.Len(t, samples, 2)
+	assert.Equal(t, "127.0.0.2:7000", samples[0]["endpoint"])
+	assert.Equal(t, 3.0, samples[0]["hints.pendingFiles"])
+	assert.Equal(t, "4.0.3", samples[0]["software.version"])
+}
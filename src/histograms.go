@@ -0,0 +1,188 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+)
+
+const (
+	histogramModePercentiles = "percentiles"
+	histogramModeSummary     = "summary"
+
+	// recentValuesAttribute is the histogram attribute with the buckets of the values recorded since it was last
+	// read, on Cassandra 4.0 and later.
+	recentValuesAttribute = "RecentValues"
+)
+
+var (
+	// percentileAliasRegex matches the percentile in the name of the histogram metrics,
+	// e.g. 'query.readLatency99thPercentileMilliseconds'.
+	percentileAliasRegex = regexp.MustCompile(`\d+thPercentile`)
+
+	// summaryStats are the histogram attributes reported in summary mode, besides the Count.
+	summaryStats = []string{"Min", "Max", "Mean"}
+
+	// recentPercentiles are the percentiles of the RecentValues buckets reported in summary mode.
+	recentPercentiles = []string{"50th", "95th", "99th"}
+)
+
+// collectionDefinitions returns the definitions with the histograms reported as set by args.HistogramMode.
+func collectionDefinitions() (Definitions, error) {
+	definitions := NewDefinitions()
+
+	switch args.HistogramMode {
+	case histogramModePercentiles:
+		if args.LegacyHistogramUnits {
+			definitions.UseLegacyHistogramUnits()
+		}
+	case histogramModeSummary:
+		definitions.SummarizeHistograms()
+	default:
+		return definitions, fmt.Errorf("unsupported histogram mode: %q, valid values are: %q, %q", args.HistogramMode, histogramModePercentiles, histogramModeSummary)
+	}
+	return definitions, nil
+}
+
+// UseLegacyHistogramUnits reports the histogram percentiles that are counts as they were reported before their
+// unit was defined: converted from microseconds and, for SSTablesPerReadHistogram, with the Milliseconds aliases.
+// It is the default until the dashboards and alerts are migrated to the corrected metrics, see LEGACY_HISTOGRAM_UNITS.
+func (d *Definitions) UseLegacyHistogramUnits() {
+	result := make([]Query, 0, len(d.ColumnFamilyMetrics))
+	for _, query := range d.ColumnFamilyMetrics {
+		legacy, found := legacyHistogramAttributes[query.MBean]
+		if !found {
+			result = append(result, query)
+			continue
+		}
+
+		var attributes []Attribute
+		for _, attr := range query.Attributes {
+			if !slices.ContainsFunc(legacy, func(l Attribute) bool { return l.Alias == attr.Alias }) {
+				attributes = append(attributes, attr)
+			}
+		}
+		query.Attributes = append(attributes, legacy...)
+		result = append(result, query)
+	}
+	d.ColumnFamilyMetrics = result
+}
+
+// SummarizeHistograms replaces the percentile attributes of the histogram queries by a summary of the histogram:
+// its Count, Min, Max and Mean, and the distribution of the values recorded during the interval, read from the
+// RecentValues buckets. The summary metrics are named after the percentile ones,
+// e.g. 'query.readLatency99thPercentileMilliseconds' is summarized as 'query.readLatencyCount',
+// 'query.readLatencyMinMilliseconds', 'query.readLatencyMaxMilliseconds', 'query.readLatencyMeanMilliseconds' and
+// 'query.readLatencyRecent50thPercentileMilliseconds' through 'query.readLatencyRecent99thPercentileMilliseconds'.
+func (d *Definitions) SummarizeHistograms() {
+	d.Common = summarizeHistograms(d.Common)
+	d.Metrics = summarizeHistograms(d.Metrics)
+	d.ColumnFamilyMetrics = summarizeHistograms(d.ColumnFamilyMetrics)
+	d.ThreadPoolMetrics = summarizeHistograms(d.ThreadPoolMetrics)
+	d.DroppedMessageMetrics = summarizeHistograms(d.DroppedMessageMetrics)
+	d.HintsMetrics = summarizeHistograms(d.HintsMetrics)
+}
+
+func summarizeHistograms(queries []Query) []Query {
+	result := make([]Query, 0, len(queries))
+	for _, query := range queries {
+		query.Attributes = summarizeAttributes(query.Attributes)
+		result = append(result, query)
+	}
+	return result
+}
+
+// summarizeAttributes returns the attributes with the percentiles replaced by the summary attributes.
+func summarizeAttributes(attributes []Attribute) []Attribute {
+	var percentile Attribute
+	result := make([]Attribute, 0, len(attributes))
+	aliases := make(map[string]struct{})
+
+	for _, attr := range attributes {
+		if percentileAliasRegex.MatchString(attr.Alias) && strings.HasSuffix(attr.MBeanAttribute, "Percentile") {
+			percentile = attr
+			continue
+		}
+		result = append(result, attr)
+		aliases[attr.Alias] = struct{}{}
+	}
+
+	if percentile.Alias == "" {
+		return attributes
+	}
+
+	loc := percentileAliasRegex.FindStringIndex(percentile.Alias)
+	summary := []Attribute{
+		{MBeanAttribute: "Count", Alias: percentile.Alias[:loc[0]] + "Count", MetricType: metric.GAUGE, Unit: unitCount},
+	}
+	// Min, Max and Mean are in the unit of the percentiles.
+	for _, stat := range summaryStats {
+		summary = append(summary, Attribute{
+			MBeanAttribute: stat,
+			Alias:          percentileAliasRegex.ReplaceAllString(percentile.Alias, stat),
+			MetricType:     metric.GAUGE,
+			Unit:           percentile.Unit,
+		})
+	}
+	for _, p := range recentPercentiles {
+		summary = append(summary, Attribute{
+			MBeanAttribute: recentValuesAttribute,
+			Alias:          percentileAliasRegex.ReplaceAllString(percentile.Alias, "Recent"+p+"Percentile"),
+			MetricType:     metric.GAUGE,
+			Unit:           percentile.Unit,
+		})
+	}
+
+	for _, attr := range summary {
+		// Some histograms already report their Count.
+		if _, found := aliases[attr.Alias]; !found {
+			result = append(result, attr)
+		}
+	}
+	return result
+}
+
+// recentValuesPercentile returns the percentile named by the alias of the RecentValues buckets, in the unit of the
+// histogram. The buckets are a long[], which nrjmx cannot read, so they are only available with the jolokia transport.
+// It returns false when the value is not a list of buckets, or the percentile can't be computed as the histogram
+// overflowed.
+func recentValuesPercentile(alias string, value interface{}) (interface{}, bool) {
+	buckets, ok := parseHistogramBuckets(value)
+	if !ok {
+		return nil, false
+	}
+	percentile, ok := aliasPercentile(alias)
+	if !ok {
+		return nil, false
+	}
+	result, ok := histogramPercentile(buckets, percentile)
+	if !ok {
+		return nil, false
+	}
+	return result, true
+}
+
+// aliasPercentile returns the percentile in the name of the metric as a fraction, e.g. 0.999 for
+// 'query.readLatency999thPercentileMilliseconds'.
+func aliasPercentile(alias string) (float64, bool) {
+	match := percentileAliasRegex.FindString(alias)
+	if match == "" {
+		return 0, false
+	}
+	digits := strings.TrimSuffix(match, "thPercentile")
+	percentile, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, false
+	}
+	return percentile / math.Pow10(len(digits)), true
+}
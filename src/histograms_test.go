@@ -0,0 +1,160 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeHistograms(t *testing.T) {
+	definitions := NewDefinitions()
+	definitions.SummarizeHistograms()
+
+	byMBean := make(map[string]Query)
+	for _, query := range definitions.Metrics {
+		byMBean[query.MBean] = query
+	}
+	for _, query := range definitions.ColumnFamilyMetrics {
+		byMBean[query.MBean] = query
+	}
+
+	assert.Equal(t, []Attribute{
+		{MBeanAttribute: "OneMinuteRate", Alias: "query.readRequestsPerSecond", MetricType: metric.GAUGE},
+		{MBeanAttribute: "Count", Alias: "query.readLatencyCount", MetricType: metric.GAUGE, Unit: unitCount},
+		{MBeanAttribute: "Min", Alias: "query.readLatencyMinMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "Max", Alias: "query.readLatencyMaxMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "Mean", Alias: "query.readLatencyMeanMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "RecentValues", Alias: "query.readLatencyRecent50thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "RecentValues", Alias: "query.readLatencyRecent95thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "RecentValues", Alias: "query.readLatencyRecent99thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+	}, byMBean["org.apache.cassandra.metrics:type=ClientRequest,scope=Read,name=Latency"].Attributes)
+
+	// The Count already reported by the histogram is not duplicated.
+	assert.Equal(t, []Attribute{
+		{MBeanAttribute: "Count", Alias: "db.tombstoneScannedHistogramCount", MetricType: metric.GAUGE},
+		{MBeanAttribute: "Min", Alias: "db.tombstoneScannedHistogramMin", MetricType: metric.GAUGE, Unit: unitCount},
+		{MBeanAttribute: "Max", Alias: "db.tombstoneScannedHistogramMax", MetricType: metric.GAUGE, Unit: unitCount},
+		{MBeanAttribute: "Mean", Alias: "db.tombstoneScannedHistogramMean", MetricType: metric.GAUGE, Unit: unitCount},
+		{MBeanAttribute: "RecentValues", Alias: "db.tombstoneScannedHistogramRecent50thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+		{MBeanAttribute: "RecentValues", Alias: "db.tombstoneScannedHistogramRecent95thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+		{MBeanAttribute: "RecentValues", Alias: "db.tombstoneScannedHistogramRecent99thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+	}, byMBean["org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=TombstoneScannedHistogram"].Attributes)
+
+	// The default definitions are not modified.
+	for _, query := range NewDefinitions().Metrics {
+		if query.MBean == "org.apache.cassandra.metrics:type=ClientRequest,scope=Read,name=Latency" {
+			assert.Len(t, query.Attributes, 7)
+		}
+	}
+}
+
+func TestCollectMetrics_SummaryHistograms(t *testing.T) {
+	i := setupCollection(t)
+	args.HistogramMode = histogramModeSummary
+
+	definitions, err := collectionDefinitions()
+	require.NoError(t, err)
+	require.NoError(t, collectMetrics(i, newFakeJMXClient(t, "cassandra.yml"), definitions))
+
+	sample := samplesByEventType(i)["CassandraSample"][0]
+	assert.NotContains(t, sample, "query.readLatency99thPercentileMilliseconds")
+	assert.Equal(t, float64(250), sample["query.readLatencyCount"])
+	assert.Equal(t, 0.8, sample["query.readLatencyMinMilliseconds"])
+	assert.Equal(t, float64(9), sample["query.readLatencyMaxMilliseconds"])
+	assert.Equal(t, 2.2, sample["query.readLatencyMeanMilliseconds"])
+}
+
+func TestCollectMetrics_SummaryHistogramsRecentValues(t *testing.T) {
+	i := setupCollection(t)
+	args.HistogramMode = histogramModeSummary
+
+	// 98 reads of up to 1ms and 2 of up to 5ms during the interval, in microsecond buckets.
+	offsets := histogramOffsets(164)
+	buckets := make([]interface{}, len(offsets)+1)
+	for i := range buckets {
+		buckets[i] = 0.0
+	}
+	fast, _ := slices.BinarySearch(offsets, 1000)
+	slow, _ := slices.BinarySearch(offsets, 5000)
+	buckets[fast], buckets[slow] = 98.0, 2.0
+
+	client := newFakeJMXClient(t, "cassandra.yml")
+	client.mBeans["org.apache.cassandra.metrics:type=ClientRequest,scope=Read,name=Latency"]["RecentValues"] = buckets
+
+	definitions, err := collectionDefinitions()
+	require.NoError(t, err)
+	require.NoError(t, collectMetrics(i, newJolokiaStub(t, client).client(t), definitions))
+
+	sample := samplesByEventType(i)["CassandraSample"][0]
+	assert.Equal(t, float64(offsets[fast])/1000, sample["query.readLatencyRecent50thPercentileMilliseconds"])
+	assert.Equal(t, float64(offsets[fast])/1000, sample["query.readLatencyRecent95thPercentileMilliseconds"])
+	assert.Equal(t, float64(offsets[slow])/1000, sample["query.readLatencyRecent99thPercentileMilliseconds"])
+	assert.Equal(t, 2.2, sample["query.readLatencyMeanMilliseconds"])
+
+	// nrjmx cannot read the buckets, only the summary gauges are reported.
+	i = setupCollection(t)
+	args.HistogramMode = histogramModeSummary
+	require.NoError(t, collectMetrics(i, client, definitions))
+	sample = samplesByEventType(i)["CassandraSample"][0]
+	assert.NotContains(t, sample, "query.readLatencyRecent99thPercentileMilliseconds")
+	assert.Equal(t, 2.2, sample["query.readLatencyMeanMilliseconds"])
+}
+
+// profilesSample returns the CassandraColumnFamilySample of the users.profiles table.
+func profilesSample(t *testing.T, samples []map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	for _, sample := range samples {
+		if sample["db.keyspaceAndColumnFamily"] == "users.profiles" {
+			return sample
+		}
+	}
+	require.Fail(t, "users.profiles sample not reported")
+	return nil
+}
+
+func TestCollectMetrics_HistogramUnits(t *testing.T) {
+	i := setupCollection(t)
+	args.LegacyHistogramUnits = false
+
+	definitions, err := collectionDefinitions()
+	require.NoError(t, err)
+	require.NoError(t, collectMetrics(i, newFakeJMXClient(t, "cassandra.yml"), definitions))
+
+	samples := samplesByEventType(i)
+	// Latencies are converted from microseconds, counts are reported as is.
+	assert.Equal(t, 5.0, samples["CassandraSample"][0]["query.readLatency99thPercentileMilliseconds"])
+	profiles := profilesSample(t, samples["CassandraColumnFamilySample"])
+	assert.Equal(t, 1.2, profiles["query.readLatency99thPercentileMilliseconds"])
+	assert.Equal(t, 3.0, profiles["db.SSTablesPerRead99thPercentile"])
+	assert.NotContains(t, profiles, "db.SSTablesPerRead99thPercentileMilliseconds")
+}
+
+func TestCollectMetrics_LegacyHistogramUnits(t *testing.T) {
+	i := setupCollection(t)
+	args.LegacyHistogramUnits = true
+
+	definitions, err := collectionDefinitions()
+	require.NoError(t, err)
+	require.NoError(t, collectMetrics(i, newFakeJMXClient(t, "cassandra.yml"), definitions))
+
+	profiles := profilesSample(t, samplesByEventType(i)["CassandraColumnFamilySample"])
+	assert.Equal(t, 3.0, profiles["db.SSTablesPerRead99thPercentile"])
+	assert.Equal(t, 0.003, profiles["db.SSTablesPerRead99thPercentileMilliseconds"])
+}
+
+func TestCollectionDefinitions_InvalidHistogramMode(t *testing.T) {
+	setupCollection(t)
+	args.HistogramMode = "distribution"
+
+	_, err := collectionDefinitions()
+	assert.EqualError(t, err, `unsupported histogram mode: "distribution", valid values are: "percentiles", "summary"`)
+}
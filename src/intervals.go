@@ -0,0 +1,231 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	staleMetricsCache = "cache"
+	staleMetricsSkip  = "skip"
+)
+
+// intervalScheduler selects the queries collected on each interval of the long-running mode. Queries with an
+// Every multiplier are collected once every Every intervals, starting with the first one. In the intervals in
+// between, their metrics are either skipped or reported with the values of the last collection.
+type intervalScheduler struct {
+	interval int
+	cache    bool
+	// previous and current keep the metrics of each sample, keyed by '<eventType>|<eventKey>'.
+	previous map[string]map[string]interface{}
+	current  map[string]map[string]interface{}
+}
+
+// newIntervalScheduler returns a scheduler handling the metrics not collected in an interval as set by mode.
+func newIntervalScheduler(mode string) (*intervalScheduler, error) {
+	if mode != staleMetricsCache && mode != staleMetricsSkip {
+		return nil, fmt.Errorf("unsupported stale metrics mode: %q, valid values are: %q, %q", mode, staleMetricsCache, staleMetricsSkip)
+	}
+
+	return &intervalScheduler{
+		cache:    mode == staleMetricsCache,
+		previous: make(map[string]map[string]interface{}),
+		current:  make(map[string]map[string]interface{}),
+	}, nil
+}
+
+// due returns the queries to be collected in the current interval. Without scheduler all of them are due.
+func (s *intervalScheduler) due(queries []Query) []Query {
+	if s == nil {
+		return queries
+	}
+
+	var result []Query
+	for _, query := range queries {
+		if s.isDue(query) {
+			result = append(result, query)
+		}
+	}
+	return result
+}
+
+// dueDefinitions returns the definitions with only the queries due in the current interval.
+func (s *intervalScheduler) dueDefinitions(definitions Definitions) Definitions {
+	result := definitions
+	result.Common = s.due(definitions.Common)
+	result.Metrics = s.due(definitions.Metrics)
+	result.ColumnFamilyMetrics = s.due(definitions.ColumnFamilyMetrics)
+	result.ThreadPoolMetrics = s.due(definitions.ThreadPoolMetrics)
+	result.DroppedMessageMetrics = s.due(definitions.DroppedMessageMetrics)
+	result.HintsMetrics = s.due(definitions.HintsMetrics)
+	return result
+}
+
+func (s *intervalScheduler) isDue(query Query) bool {
+	return query.Every <= 1 || s.interval%query.Every == 0
+}
+
+// restore sets the values of the last collection for the metrics of the queries not due in the current
+// interval, and keeps the sample metrics for the next intervals.
+func (s *intervalScheduler) restore(ms *metric.Set, sampleKey string, queryConfigs ...[]Query) {
+	if s == nil || !s.cache {
+		return
+	}
+
+	cached := s.previous[sampleKey]
+	for _, queries := range queryConfigs {
+		for _, query := range queries {
+			if s.isDue(query) {
+				continue
+			}
+			for _, attr := range query.Attributes {
+				if value, found := cached[attr.Alias]; found {
+					setCachedMetric(ms, attr.Alias, value)
+				}
+			}
+		}
+	}
+
+	metrics := make(map[string]interface{}, len(ms.Metrics))
+	for name, value := range ms.Metrics {
+		metrics[name] = value
+	}
+	s.current[sampleKey] = metrics
+}
+
+// replay reports the samples of the event type from the last collection. It is used when none of the queries
+// of the sample is due in the current interval.
+func (s *intervalScheduler) replay(e *integration.Entity, eventType string) {
+	if s == nil || !s.cache {
+		return
+	}
+
+	var sampleKeys []string
+	for sampleKey := range s.previous {
+		if strings.HasPrefix(sampleKey, eventType+"|") {
+			sampleKeys = append(sampleKeys, sampleKey)
+		}
+	}
+	sort.Strings(sampleKeys)
+
+	for _, sampleKey := range sampleKeys {
+		ms := metricSet(e, eventType, args.Hostname, args.Port, args.RemoteMonitoring)
+		for name, value := range s.previous[sampleKey] {
+			if _, found := ms.Metrics[name]; !found {
+				setCachedMetric(ms, name, value)
+			}
+		}
+		s.current[sampleKey] = s.previous[sampleKey]
+	}
+}
+
+// next moves to the next interval. The samples not reported in the current interval are discarded.
+func (s *intervalScheduler) next() {
+	s.interval++
+	s.previous = s.current
+	s.current = make(map[string]map[string]interface{})
+}
+
+// discard drops the samples of a failed collection. The interval is collected again on the next one, with the
+// cache of the last successful collection.
+func (s *intervalScheduler) discard() {
+	s.current = make(map[string]map[string]interface{})
+}
+
+// setCachedMetric sets a value already processed by the metric.Set, so it is reported as is.
+func setCachedMetric(ms *metric.Set, name string, value interface{}) {
+	metricType := metric.GAUGE
+	if _, isString := value.(string); isString {
+		metricType = metric.ATTRIBUTE
+	}
+
+	if err := ms.SetMetric(name, value, metricType); err != nil {
+		log.Debug("Failed to set cached metric value: %v", err)
+	}
+}
+
+func sampleKey(eventType, eventKey string) string {
+	return eventType + "|" + eventKey
+}
+
+// loadIntervalMultipliers unmarshal the YAML map of definition groups or MBeans to their interval multiplier.
+func loadIntervalMultipliers(cfg string) (map[string]int, error) {
+	result := make(map[string]int)
+
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(cfg)))
+	if err := decoder.Decode(&result); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetIntervalMultipliers overrides the Every multiplier of the queries. The multipliers are keyed by the
+// definitions group, like 'column_family_metrics', or by the MBean of the query, which has precedence.
+func (d *Definitions) SetIntervalMultipliers(multipliers map[string]int) error {
+	if len(multipliers) == 0 {
+		return nil
+	}
+
+	groups := map[string][]Query{
+		"common":                  d.Common,
+		"metrics":                 d.Metrics,
+		"column_family_metrics":   d.ColumnFamilyMetrics,
+		"thread_pool_metrics":     d.ThreadPoolMetrics,
+		"dropped_message_metrics": d.DroppedMessageMetrics,
+		"hints_metrics":           d.HintsMetrics,
+	}
+
+	known := make(map[string]bool)
+	for group, queries := range groups {
+		known[group] = true
+		for _, query := range queries {
+			known[query.MBean] = true
+		}
+	}
+
+	for key, every := range multipliers {
+		if !known[key] {
+			return fmt.Errorf("unknown definitions group or MBean: %q", key)
+		}
+		if every < 1 {
+			return fmt.Errorf("invalid interval multiplier for %q: %d, it must be 1 or greater", key, every)
+		}
+	}
+
+	for group, queries := range groups {
+		// The queries are copied, so the multipliers are not set on the shared default definitions.
+		updated := make([]Query, len(queries))
+		for i, query := range queries {
+			if every, found := multipliers[group]; found {
+				query.Every = every
+			}
+			if every, found := multipliers[query.MBean]; found {
+				query.Every = every
+			}
+			updated[i] = query
+		}
+		groups[group] = updated
+	}
+
+	d.Common = groups["common"]
+	d.Metrics = groups["metrics"]
+	d.ColumnFamilyMetrics = groups["column_family_metrics"]
+	d.ThreadPoolMetrics = groups["thread_pool_metrics"]
+	d.DroppedMessageMetrics = groups["dropped_message_metrics"]
+	d.HintsMetrics = groups["hints_metrics"]
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectInterval collects the metrics of the scheduler current interval and returns the reported samples.
+func collectInterval(t *testing.T, i *integration.Integration, definitions Definitions, scheduler *intervalScheduler) map[string][]map[string]interface{} {
+	t.Helper()
+
+	require.NoError(t, collectScheduledMetrics(i, newFakeJMXClient(t, "cassandra.yml"), definitions, scheduler))
+	scheduler.next()
+
+	samples := samplesByEventType(i)
+	require.NoError(t, i.Publish())
+	return samples
+}
+
+func TestCollectScheduledMetrics_Cache(t *testing.T) {
+	i := setupCollection(t)
+
+	definitions := NewDefinitions()
+	require.NoError(t, definitions.SetIntervalMultipliers(map[string]int{
+		"column_family_metrics":                               3,
+		"org.apache.cassandra.metrics:type=Storage,name=Load": 2,
+	}))
+
+	scheduler, err := newIntervalScheduler(staleMetricsCache)
+	require.NoError(t, err)
+
+	first := collectInterval(t, i, definitions, scheduler)
+	second := collectInterval(t, i, definitions, scheduler)
+
+	// Not refreshed metrics are reported with the values of the last collection.
+	assert.Equal(t, first["CassandraSample"][0]["db.loadBytes"], second["CassandraSample"][0]["db.loadBytes"])
+	assert.Equal(t, first["CassandraSample"][0]["client.connectedNativeClients"], second["CassandraSample"][0]["client.connectedNativeClients"])
+	require.Len(t, second["CassandraColumnFamilySample"], len(first["CassandraColumnFamilySample"]))
+	assert.Equal(t, first["CassandraColumnFamilySample"], second["CassandraColumnFamilySample"])
+}
+
+func TestIntervalScheduler_Discard(t *testing.T) {
+	i := setupCollection(t)
+
+	definitions := NewDefinitions()
+	require.NoError(t, definitions.SetIntervalMultipliers(map[string]int{"column_family_metrics": 3}))
+
+	scheduler, err := newIntervalScheduler(staleMetricsCache)
+	require.NoError(t, err)
+
+	first := collectInterval(t, i, definitions, scheduler)
+
+	failing := newFakeJMXClient(t, "cassandra.yml")
+	failing.inject("org.apache.cassandra.metrics:type=Storage,*", faultClientError)
+	require.Error(t, collectScheduledMetrics(i, failing, definitions, scheduler))
+	scheduler.discard()
+	i.Clear()
+
+	assert.Equal(t, 1, scheduler.interval, "a failed collection doesn't advance the interval")
+	retried := collectInterval(t, i, definitions, scheduler)
+	assert.Equal(t, first["CassandraColumnFamilySample"], retried["CassandraColumnFamilySample"])
+}
+
+func TestCollectScheduledMetrics_Skip(t *testing.T) {
+	i := setupCollection(t)
+
+	definitions := NewDefinitions()
+	require.NoError(t, definitions.SetIntervalMultipliers(map[string]int{
+		"column_family_metrics":                               3,
+		"org.apache.cassandra.metrics:type=Storage,name=Load": 2,
+	}))
+
+	scheduler, err := newIntervalScheduler(staleMetricsSkip)
+	require.NoError(t, err)
+
+	first := collectInterval(t, i, definitions, scheduler)
+	assert.NotEmpty(t, first["CassandraColumnFamilySample"])
+	assert.Contains(t, first["CassandraSample"][0], "db.loadBytes")
+
+	second := collectInterval(t, i, definitions, scheduler)
+	assert.Empty(t, second["CassandraColumnFamilySample"])
+	assert.NotContains(t, second["CassandraSample"][0], "db.loadBytes")
+	assert.Contains(t, second["CassandraSample"][0], "client.connectedNativeClients")
+
+	third := collectInterval(t, i, definitions, scheduler)
+	assert.Contains(t, third["CassandraSample"][0], "db.loadBytes")
+	assert.Empty(t, third["CassandraColumnFamilySample"])
+
+	fourth := collectInterval(t, i, definitions, scheduler)
+	assert.NotEmpty(t, fourth["CassandraColumnFamilySample"])
+}
+
+func TestSetIntervalMultipliers(t *testing.T) {
+	definitions := NewDefinitions()
+	require.NoError(t, definitions.SetIntervalMultipliers(map[string]int{
+		"common": 5,
+		"org.apache.cassandra.db:type=StorageService": 10,
+	}))
+
+	for _, query := range definitions.Common {
+		expected := 5
+		if query.MBean == "org.apache.cassandra.db:type=StorageService" {
+			expected = 10
+		}
+		assert.Equal(t, expected, query.Every, query.MBean)
+	}
+	assert.Zero(t, definitions.Metrics[0].Every)
+
+	// The default definitions are not modified.
+	assert.Zero(t, NewDefinitions().Common[0].Every)
+
+	assert.EqualError(t, definitions.SetIntervalMultipliers(map[string]int{"tables": 2}),
+		`unknown definitions group or MBean: "tables"`)
+	assert.EqualError(t, definitions.SetIntervalMultipliers(map[string]int{"metrics": 0}),
+		`invalid interval multiplier for "metrics": 0, it must be 1 or greater`)
+}
+
+func TestNewIntervalScheduler_InvalidMode(t *testing.T) {
+	_, err := newIntervalScheduler("drop")
+	assert.Error(t, err)
+}
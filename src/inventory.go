@@ -7,8 +7,14 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/newrelic/infra-integrations-sdk/v3/log"
 
@@ -40,31 +46,92 @@ func getInventory() (map[string]interface{}, error) {
 	return i, nil
 }
 
-func populateInventory(i *inventory.Inventory, rawInventory inventory.Item) error {
+// populateInventory sets an inventory item for each top level setting. Nested maps and lists are flattened into
+// fields named by their dotted path, e.g. 'seed_provider' fields are '0.class_name' and '0.parameters.0.seeds'.
+// Lists of scalars are joined into a single field. Top level scalars are set in the 'value' field.
+// The values of the fields whose dotted path matches any of the redact patterns are omitted.
+func populateInventory(i *inventory.Inventory, rawInventory inventory.Item, redact redactPatterns) error {
 	for k, v := range rawInventory {
-		switch value := v.(type) {
-		case map[interface{}]interface{}:
-			for subk, subv := range value {
-				switch subVal := subv.(type) {
-				case []interface{}:
-					//TODO: Do not include lists for now
-				default:
-					setValue(i, k, subk.(string), subVal)
-				}
+		fields := make(map[string]interface{})
+		flattenInventory(fields, "", v)
+
+		for field, value := range fields {
+			if field == "" {
+				field = "value"
 			}
-		case []interface{}:
-			//TODO: Do not include lists for now
-		default:
-			setValue(i, k, "value", value)
+			setValue(i, k, field, truncateValue(value, args.InventoryMaxValueLength), redact)
 		}
 	}
 	return nil
 }
 
-func setValue(i *inventory.Inventory, key string, field string, value interface{}) {
-	re, _ := regexp.Compile("(?i)password")
+// flattenInventory adds the value to the fields under its path, recursing into nested maps and lists.
+func flattenInventory(fields map[string]interface{}, path string, value interface{}) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		for subk, subv := range v {
+			flattenInventory(fields, inventoryPath(path, fmt.Sprint(subk)), subv)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return
+		}
+		if joined, ok := joinScalars(v); ok {
+			fields[path] = joined
+			return
+		}
+		for idx, item := range v {
+			flattenInventory(fields, inventoryPath(path, strconv.Itoa(idx)), item)
+		}
+	default:
+		fields[path] = v
+	}
+}
+
+func inventoryPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// joinScalars joins the list values separated by commas, it returns false if any of them is a map or a list.
+func joinScalars(values []interface{}) (string, bool) {
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		switch value.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return "", false
+		}
+		items = append(items, fmt.Sprint(value))
+	}
+	return strings.Join(items, ", "), true
+}
+
+// truncateValue shortens the strings longer than maxLength, so huge settings don't blow up the inventory payload.
+// A maxLength of 0 means no limit.
+func truncateValue(value interface{}, maxLength int) interface{} {
+	str, ok := value.(string)
+	if !ok || maxLength <= 0 || len(str) <= maxLength {
+		return value
+	}
+	return truncateString(str, maxLength) + "...(truncated)"
+}
+
+// truncateString cuts the string to at most maxLength bytes, backing up to the start of a rune so multi-byte
+// characters are not split and the result stays valid UTF-8.
+func truncateString(value string, maxLength int) string {
+	if len(value) <= maxLength {
+		return value
+	}
+	for maxLength > 0 && !utf8.RuneStart(value[maxLength]) {
+		maxLength--
+	}
+	return value[:maxLength]
+}
 
-	if re.MatchString(key) || re.MatchString(field) {
+func setValue(i *inventory.Inventory, key string, field string, value interface{}, redact redactPatterns) {
+	if redact.matches(inventoryItemPath(key, field)) {
 		value = "(omitted value)"
 	}
 	err := i.SetItem(key, field, value)
@@ -72,3 +139,57 @@ func setValue(i *inventory.Inventory, key string, field string, value interface{
 		log.Error("setting item: %v", err)
 	}
 }
+
+// inventoryItemPath returns the full dotted path of the inventory field, e.g.
+// 'client_encryption_options.keystore_password'. Top level settings are set in the 'value' field, so their path
+// is the setting name.
+func inventoryItemPath(key, field string) string {
+	if field == "value" {
+		return key
+	}
+	return inventoryPath(key, field)
+}
+
+// defaultRedactPatterns match the paths of the settings holding secrets, or the location of the files holding
+// them, in cassandra.yaml and the JVM options.
+var defaultRedactPatterns = []string{
+	`(?i)password`,
+	`(?i)passphrase`,
+	`(?i)secret`,
+	`(?i)credentials?$`,
+	`(?i)private_?key`,
+	`(?i)(key|trust)_?store[^.]*$`,
+	`(?i)(api|access|auth|bearer|refresh|session)[_-]?token`,
+	`(?i)bind_?dn`,
+}
+
+// redactPatterns are the compiled regular expressions matching the inventory paths whose values are omitted.
+type redactPatterns []*regexp.Regexp
+
+// loadRedactPatterns compiles the default patterns and the ones in the YAML list cfg.
+func loadRedactPatterns(cfg string) (redactPatterns, error) {
+	var extra []string
+	decoder := yaml.NewDecoder(strings.NewReader(cfg))
+	if err := decoder.Decode(&extra); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var result redactPatterns
+	for _, pattern := range append(slices.Clone(defaultRedactPatterns), extra...) {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+		}
+		result = append(result, regex)
+	}
+	return result, nil
+}
+
+func (r redactPatterns) matches(path string) bool {
+	for _, regex := range r {
+		if regex.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRedactPatterns(t *testing.T) redactPatterns {
+	t.Helper()
+
+	redact, err := loadRedactPatterns("")
+	require.NoError(t, err)
+	return redact
+}
+
+func TestRedactPatterns_SecretSettings(t *testing.T) {
+	redact := testRedactPatterns(t)
+
+	secrets := []string{
+		// cassandra.yaml, Cassandra 3.x and later.
+		"server_encryption_options.keystore",
+		"server_encryption_options.keystore_password",
+		"server_encryption_options.truststore",
+		"server_encryption_options.truststore_password",
+		"client_encryption_options.keystore",
+		"client_encryption_options.keystore_password",
+		"client_encryption_options.truststore",
+		"client_encryption_options.truststore_password",
+		"transparent_data_encryption_options.key_provider.0.parameters.0.keystore",
+		"transparent_data_encryption_options.key_provider.0.parameters.0.keystore_password",
+		"transparent_data_encryption_options.key_provider.0.parameters.0.key_password",
+		// cassandra.yaml, Cassandra 4.x.
+		"server_encryption_options.outbound_keystore",
+		"server_encryption_options.outbound_keystore_password",
+		"jmx_encryption_options.keystore",
+		"jmx_encryption_options.keystore_password",
+		"jmx_encryption_options.truststore_password",
+		// cassandra.yaml, Cassandra 5.x.
+		"client_encryption_options.ssl_context_factory.parameters.private_key",
+		"client_encryption_options.ssl_context_factory.parameters.private_key_password",
+		"server_encryption_options.ssl_context_factory.parameters.private_key",
+		"jmx_server_options.password_file",
+		"jmx_server_options.jmx_encryption_options.keystore_password",
+		// LDAP authentication.
+		"ldap_bind_password",
+		"ldap.service_password",
+		"ldap.bind_dn",
+		// JVM options and JMX credentials.
+		"jvm_options.javax.net.ssl.keyStore",
+		"jvm_options.javax.net.ssl.keyStorePassword",
+		"jvm_options.javax.net.ssl.trustStore",
+		"jvm_options.javax.net.ssl.trustStorePassword",
+		"jvm_options.com.sun.management.jmxremote.password.file",
+		"cassandra_env.JMX_PASSWORD",
+		// Generic secrets.
+		"audit_logging_options.client_secret",
+		"sidecar.auth_token",
+		"sidecar.api_token",
+		"sidecar.credentials",
+		"my_important_password",
+	}
+	for _, path := range secrets {
+		assert.True(t, redact.matches(path), path)
+	}
+
+	settings := []string{
+		"cluster_name",
+		"num_tokens",
+		"initial_token",
+		"allocate_tokens_for_local_replication_factor",
+		"credentials_validity_in_ms",
+		"credentials_update_interval",
+		"key_cache_size_in_mb",
+		"server_encryption_options.internode_encryption",
+		"client_encryption_options.enabled",
+		"authenticator",
+		"seed_provider.0.parameters.0.seeds",
+	}
+	for _, path := range settings {
+		assert.False(t, redact.matches(path), path)
+	}
+}
+
+func TestLoadRedactPatterns_Extra(t *testing.T) {
+	redact, err := loadRedactPatterns(`
+- "^seed_provider\\."
+- "(?i)internode"
+`)
+	require.NoError(t, err)
+
+	assert.True(t, redact.matches("seed_provider.0.parameters.0.seeds"))
+	assert.True(t, redact.matches("server_encryption_options.internode_encryption"))
+	// The default patterns are still applied.
+	assert.True(t, redact.matches("client_encryption_options.keystore_password"))
+	assert.False(t, redact.matches("cluster_name"))
+}
+
+func TestLoadRedactPatterns_Invalid(t *testing.T) {
+	_, err := loadRedactPatterns(`["(unclosed"]`)
+	assert.ErrorContains(t, err, `invalid regular expression "(unclosed"`)
+
+	_, err = loadRedactPatterns(`password: true`)
+	assert.Error(t, err)
+}
+
+func TestTruncateValue(t *testing.T) {
+	assert.Equal(t, "/data...(truncated)", truncateValue("/data1", 5))
+	assert.Equal(t, "/data1", truncateValue("/data1", 0))
+	assert.Equal(t, 256, truncateValue(256, 2))
+
+	// 'é' takes 2 bytes, it is not split.
+	truncated := truncateValue("café crème", 4)
+	assert.Equal(t, "caf...(truncated)", truncated)
+	assert.True(t, utf8.ValidString(truncated.(string)))
+	assert.Equal(t, "café...(truncated)", truncateValue("café crème", 5))
+}
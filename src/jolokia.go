@@ -0,0 +1,333 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/newrelic/nrjmx/gojmx"
+)
+
+const (
+	transportJMX     = "jmx"
+	transportJolokia = "jolokia"
+
+	jolokiaSearch = "search"
+	jolokiaRead   = "read"
+)
+
+var errJolokiaResponseCount = errors.New("unexpected number of jolokia responses")
+
+// jolokiaConfig keeps the settings to reach the Jolokia agent.
+type jolokiaConfig struct {
+	URL                string
+	Username           string
+	Password           string
+	Timeout            time.Duration
+	CACert             string
+	ClientCert         string
+	ClientKey          string
+	InsecureSkipVerify bool
+}
+
+// jolokiaRequest is a single operation in the Jolokia JSON protocol.
+type jolokiaRequest struct {
+	Type      string                 `json:"type"`
+	MBean     string                 `json:"mbean"`
+	Attribute []string               `json:"attribute,omitempty"`
+	Config    map[string]interface{} `json:"config,omitempty"`
+}
+
+// jolokiaResponse is the result of a single jolokiaRequest.
+type jolokiaResponse struct {
+	Status    int             `json:"status"`
+	Value     json.RawMessage `json:"value"`
+	Error     string          `json:"error"`
+	ErrorType string          `json:"error_type"`
+}
+
+// jolokiaClient is an MBeanSource that queries the MBeans through the Jolokia HTTP agent.
+type jolokiaClient struct {
+	config     jolokiaConfig
+	httpClient *http.Client
+}
+
+var (
+	_ MBeanSource           = (*jolokiaClient)(nil)
+	_ attributesBulkQuerier = (*jolokiaClient)(nil)
+)
+
+// newJolokiaClient prepares the HTTP client used to reach the Jolokia agent.
+func newJolokiaClient(config jolokiaConfig) (*jolokiaClient, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CACert != "" {
+		caCert, err := os.ReadFile(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jolokia CA certificate: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse jolokia CA certificate: %s", config.CACert)
+		}
+	}
+
+	if config.ClientCert != "" && config.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load jolokia client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &jolokiaClient{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: config.Timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}, nil
+}
+
+// QueryMBeanNames uses the Jolokia search operation to expand the pattern.
+func (c *jolokiaClient) QueryMBeanNames(mBeanGlobPattern string) ([]string, error) {
+	responses, err := c.do([]jolokiaRequest{newJolokiaRequest(jolokiaSearch, mBeanGlobPattern)})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := responses[0].err(); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if err := json.Unmarshal(responses[0].Value, &names); err != nil {
+		return nil, fmt.Errorf("failed to decode jolokia search response for %q: %w", mBeanGlobPattern, err)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (c *jolokiaClient) QueryMBeanAttributes(mBeanNamePattern string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error) {
+	result := c.QueryMBeanAttributesBulk([]Query{newAttributesQuery(mBeanNamePattern, mBeanAttrName)})
+	return result[0].attributes, result[0].err
+}
+
+func (c *jolokiaClient) GetMBeanAttributes(mBeanName string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error) {
+	return c.QueryMBeanAttributes(mBeanName, mBeanAttrName...)
+}
+
+// QueryMBeanAttributesBulk sends all the queries as a single Jolokia bulk read request.
+func (c *jolokiaClient) QueryMBeanAttributesBulk(queries []Query) []attributesResult {
+	results := make([]attributesResult, len(queries))
+
+	requests := make([]jolokiaRequest, len(queries))
+	for i, query := range queries {
+		requests[i] = newJolokiaRequest(jolokiaRead, query.MBean, query.GetAttributeNames()...)
+	}
+
+	responses, err := c.do(requests)
+	if err != nil {
+		for i := range results {
+			results[i].err = err
+		}
+		return results
+	}
+
+	for i, query := range queries {
+		if err := responses[i].err(); err != nil {
+			results[i].err = err
+			continue
+		}
+		results[i].attributes, results[i].err = toAttributeResponses(query, responses[i].Value)
+	}
+	return results
+}
+
+// IsRunning always returns true since each Jolokia request is independent.
+func (c *jolokiaClient) IsRunning() bool {
+	return true
+}
+
+func (c *jolokiaClient) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// do posts the requests to the Jolokia agent.
+func (c *jolokiaClient) do(requests []jolokiaRequest) ([]jolokiaResponse, error) {
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode jolokia request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jolokia request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.Username != "" {
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach jolokia agent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected jolokia response status: %s", resp.Status)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jolokia response: %w", err)
+	}
+
+	var responses []jolokiaResponse
+	if err := json.Unmarshal(content, &responses); err != nil {
+		return nil, fmt.Errorf("failed to decode jolokia response: %w", err)
+	}
+
+	if len(responses) != len(requests) {
+		return nil, fmt.Errorf("%w: expected %d responses, got %d", errJolokiaResponseCount, len(requests), len(responses))
+	}
+
+	return responses, nil
+}
+
+// err converts the Jolokia failed responses into JMXErrors, so they are handled as failed JMX queries.
+func (r jolokiaResponse) err() error {
+	if r.Status == http.StatusOK {
+		return nil
+	}
+	return &gojmx.JMXError{
+		Message:      r.Error,
+		CauseMessage: fmt.Sprintf("status: %d, type: %s", r.Status, r.ErrorType),
+	}
+}
+
+func newJolokiaRequest(requestType, mBean string, attributes ...string) jolokiaRequest {
+	return jolokiaRequest{
+		Type:      requestType,
+		MBean:     mBean,
+		Attribute: attributes,
+		Config: map[string]interface{}{
+			// Keep the mBean name properties in the declared order, as returned by nrjmx.
+			"canonicalNaming": false,
+			// Report the values of the MBeans that have the attributes when reading patterns.
+			"ignoreErrors": true,
+		},
+	}
+}
+
+func newAttributesQuery(mBean string, attrNames []string) Query {
+	query := Query{MBean: mBean}
+	for _, attrName := range attrNames {
+		query.Attributes = append(query.Attributes, Attribute{MBeanAttribute: attrName})
+	}
+	return query
+}
+
+// toAttributeResponses converts the value of a read response into the nrjmx format. Reading a pattern returns
+// the values keyed by mBean name, otherwise the attribute values are returned directly.
+func toAttributeResponses(query Query, value json.RawMessage) ([]*gojmx.AttributeResponse, error) {
+	valuesByMBean := make(map[string]map[string]interface{})
+
+	if isMBeanPattern(query.MBean) {
+		if err := json.Unmarshal(value, &valuesByMBean); err != nil {
+			return nil, fmt.Errorf("failed to decode jolokia read response for %q: %w", query.MBean, err)
+		}
+	} else {
+		values := make(map[string]interface{})
+		if err := json.Unmarshal(value, &values); err != nil {
+			return nil, fmt.Errorf("failed to decode jolokia read response for %q: %w", query.MBean, err)
+		}
+		valuesByMBean[query.MBean] = values
+	}
+
+	mBeanNames := make([]string, 0, len(valuesByMBean))
+	for mBeanName := range valuesByMBean {
+		mBeanNames = append(mBeanNames, mBeanName)
+	}
+	sort.Strings(mBeanNames)
+
+	var result []*gojmx.AttributeResponse
+	for _, mBeanName := range mBeanNames {
+		values := valuesByMBean[mBeanName]
+
+		attrNames := query.GetAttributeNames()
+		if len(attrNames) == 0 {
+			for attrName := range values {
+				attrNames = append(attrNames, attrName)
+			}
+			sort.Strings(attrNames)
+		}
+
+		for _, attrName := range attrNames {
+			attrValue, found := values[attrName]
+			result = append(result, toAttributeResponse(mBeanName, attrName, attrValue, found))
+		}
+	}
+	return result, nil
+}
+
+func toAttributeResponse(mBeanName, attrName string, value interface{}, found bool) *gojmx.AttributeResponse {
+	response := &gojmx.AttributeResponse{
+		Name: fmt.Sprintf("%s,attr=%s", mBeanName, attrName),
+	}
+
+	switch v := value.(type) {
+	case float64:
+		response.ResponseType = gojmx.ResponseTypeDouble
+		response.DoubleValue = v
+	case string:
+		response.ResponseType = gojmx.ResponseTypeString
+		response.StringValue = v
+	case bool:
+		response.ResponseType = gojmx.ResponseTypeBool
+		response.BoolValue = v
+	case []interface{}:
+		// Arrays, like the buckets of the histogram gauges, are reported as a list of values.
+		values := make([]string, len(v))
+		for i, item := range v {
+			values[i] = fmt.Sprintf("%v", item)
+		}
+		response.ResponseType = gojmx.ResponseTypeString
+		response.StringValue = strings.Join(values, ",")
+	default:
+		response.ResponseType = gojmx.ResponseTypeErr
+		if found {
+			response.StatusMsg = fmt.Sprintf("unsupported value type: %T", v)
+		} else {
+			response.StatusMsg = "attribute not found"
+		}
+	}
+	return response
+}
+
+func isMBeanPattern(mBean string) bool {
+	return strings.ContainsAny(mBean, "*?")
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/nrjmx/gojmx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jolokiaStub serves the Jolokia search and read operations for the MBeans of a fakeJMXClient.
+type jolokiaStub struct {
+	fake       *fakeJMXClient
+	username   string
+	password   string
+	bulkSizes  []int
+	httpServer *httptest.Server
+}
+
+func newJolokiaStub(t *testing.T, fake *fakeJMXClient) *jolokiaStub {
+	t.Helper()
+
+	stub := &jolokiaStub{fake: fake, username: "jolokia", password: "secret"}
+	stub.httpServer = httptest.NewServer(http.HandlerFunc(stub.handle))
+	t.Cleanup(stub.httpServer.Close)
+
+	return stub
+}
+
+func (s *jolokiaStub) client(t *testing.T) *jolokiaClient {
+	t.Helper()
+
+	client, err := newJolokiaClient(jolokiaConfig{
+		URL:      s.httpServer.URL,
+		Username: s.username,
+		Password: s.password,
+		Timeout:  time.Second,
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func (s *jolokiaStub) handle(w http.ResponseWriter, r *http.Request) {
+	if username, password, _ := r.BasicAuth(); username != s.username || password != s.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var requests []jolokiaRequest
+	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.bulkSizes = append(s.bulkSizes, len(requests))
+
+	responses := make([]map[string]interface{}, len(requests))
+	for i, request := range requests {
+		responses[i] = s.response(request)
+	}
+
+	_ = json.NewEncoder(w).Encode(responses)
+}
+
+func (s *jolokiaStub) response(request jolokiaRequest) map[string]interface{} {
+	if request.Config["canonicalNaming"] != false {
+		return jolokiaError(http.StatusBadRequest, "canonical naming is not supported by the stub")
+	}
+
+	if err := s.fake.fault(request.MBean); err != nil {
+		return jolokiaError(http.StatusInternalServerError, err.Error())
+	}
+
+	names := s.fake.matchingNames(request.MBean)
+	if len(names) == 0 {
+		return jolokiaError(http.StatusNotFound, "javax.management.InstanceNotFoundException: "+request.MBean)
+	}
+
+	if request.Type == jolokiaSearch {
+		return map[string]interface{}{"status": http.StatusOK, "value": names}
+	}
+
+	valuesByMBean := make(map[string]map[string]interface{})
+	for _, name := range names {
+		values := make(map[string]interface{})
+		for _, attr := range s.fake.attributes(name, request.Attribute) {
+			attrName := attr.Name[len(name)+len(",attr="):]
+			if array, isArray := s.fake.mBeans[name][attrName].([]interface{}); isArray {
+				values[attrName] = array
+			} else if attr.ResponseType != gojmx.ResponseTypeErr {
+				values[attrName] = attr.GetValue()
+			}
+		}
+		if len(values) > 0 {
+			valuesByMBean[name] = values
+		}
+	}
+
+	if !isMBeanPattern(request.MBean) {
+		return map[string]interface{}{"status": http.StatusOK, "value": valuesByMBean[request.MBean]}
+	}
+	return map[string]interface{}{"status": http.StatusOK, "value": valuesByMBean}
+}
+
+func jolokiaError(status int, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"status":     status,
+		"error":      message,
+		"error_type": "javax.management.JMException",
+	}
+}
+
+func TestJolokia_CollectMetrics(t *testing.T) {
+	i := setupCollection(t)
+	args.ThreadPoolMetrics = true
+	stub := newJolokiaStub(t, newFakeJMXClient(t, "cassandra.yml"))
+
+	require.NoError(t, collectMetrics(i, stub.client(t), NewDefinitions()))
+
+	samples := samplesByEventType(i)
+	sample := samples["CassandraSample"][0]
+	assert.Equal(t, "4.0.3", sample["software.version"])
+	assert.Equal(t, 8.0, sample["client.connectedNativeClients"])
+	assert.Equal(t, 5.0, sample["query.readLatency99thPercentileMilliseconds"])
+
+	columnFamilies := make(map[string]map[string]interface{})
+	for _, cf := range samples["CassandraColumnFamilySample"] {
+		columnFamilies[cf["db.keyspaceAndColumnFamily"].(string)] = cf
+	}
+	require.Len(t, columnFamilies, 3)
+	assert.Equal(t, 4.0, columnFamilies["users.profiles"]["db.liveSSTableCount"])
+	assert.Equal(t, 1.2, columnFamilies["users.profiles"]["query.readLatency99thPercentileMilliseconds"])
+
+	assert.Len(t, samples["CassandraThreadPoolSample"], 2)
+
+	// Node level metrics are requested through a single bulk request.
+	assert.Contains(t, stub.bulkSizes, len(metricDefinitions))
+}
+
+func TestJolokia_QueryMBeanNames(t *testing.T) {
+	stub := newJolokiaStub(t, newFakeJMXClient(t, "cassandra.yml"))
+
+	names, err := stub.client(t).QueryMBeanNames("org.apache.cassandra.metrics:type=Table,keyspace=users,scope=*,name=LiveSSTableCount")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"org.apache.cassandra.metrics:type=Table,keyspace=users,scope=profiles,name=LiveSSTableCount",
+		"org.apache.cassandra.metrics:type=Table,keyspace=users,scope=sessions,name=LiveSSTableCount",
+	}, names)
+}
+
+func TestJolokia_Errors(t *testing.T) {
+	fake := newFakeJMXClient(t, "cassandra.yml")
+	stub := newJolokiaStub(t, fake)
+	client := stub.client(t)
+
+	_, err := client.GetMBeanAttributes("org.apache.cassandra.metrics:type=Unknown,name=Missing", "Value")
+	_, isJMXErr := gojmx.IsJMXError(err)
+	assert.True(t, isJMXErr, "failed operations should be reported as JMX errors")
+
+	stub.password = "other"
+	_, err = client.QueryMBeanNames("org.apache.cassandra.db:*")
+	assert.Error(t, err)
+	_, isJMXErr = gojmx.IsJMXError(err)
+	assert.False(t, isJMXErr, "failed requests should not be reported as JMX errors")
+}
+
+func TestJolokia_ArrayAttribute(t *testing.T) {
+	buckets := make([]interface{}, 151)
+	for i := range buckets {
+		buckets[i] = float64(0)
+	}
+	buckets[34] = float64(98)
+
+	response := toAttributeResponse("org.apache.cassandra.metrics:type=Table,keyspace=users,scope=profiles,name=EstimatedPartitionSizeHistogram", "Value", buckets, true)
+	require.Equal(t, gojmx.ResponseTypeString, response.ResponseType)
+	assert.True(t, strings.HasPrefix(response.StringValue, "0,0,0,"))
+	assert.Equal(t, "98", strings.Split(response.StringValue, ",")[34])
+	assert.Equal(t, int64(1109), partitionSizeStat("db.partitionSize50thPercentileBytes", response.StringValue, 100))
+}
@@ -0,0 +1,242 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/event"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+const (
+	logEventCategory = "cassandra"
+
+	logEventGCPause          = "gcPause"
+	logEventDroppedMessages  = "droppedMessages"
+	logEventDroppedMutation  = "droppedMutation"
+	logEventTombstones       = "tombstoneThreshold"
+	logEventLargePartition   = "largePartition"
+	logEventHintedHandoff    = "hintedHandoff"
+	logEventGossipTransition = "gossipTransition"
+
+	// maxLogMessageLength is the length the messages reported with the events are truncated to.
+	maxLogMessageLength = 4096
+)
+
+var (
+	// logLineRegex matches the default logback pattern of the system.log: level, thread, timestamp, file and line,
+	// and the message. Lines not matching it, like the stack traces, are ignored.
+	logLineRegex = regexp.MustCompile(`^([A-Z]+)\s+\[([^\]]*)\]\s+(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}[,.]\d{3})\s+(\S+)\s+-\s+(.*)$`)
+
+	gcPauseRegex         = regexp.MustCompile(`^(.+?) GC in (\d+)ms\.`)
+	droppedMessagesRegex = regexp.MustCompile(`^(\w+) messages were dropped in (?:the )?last (\d+) ms: (\d+) internal and (\d+) cross node`)
+	droppedMutationRegex = regexp.MustCompile(`(?i)dropping mutation`)
+	tombstoneWarnRegex   = regexp.MustCompile(`^Read (\d+) live rows and (\d+) tombstone cells for query (.*)`)
+	tombstoneFailRegex   = regexp.MustCompile(`^Scanned over (\d+) tombstones (?:during query|in) (.*)`)
+	tombstoneRegex       = regexp.MustCompile(`(?i)tombstone threshold exceeded`)
+	queryTableRegex      = regexp.MustCompile(`(?i)\bFROM\s+"?(\w+)"?\."?(\w+)"?`)
+	largePartitionRegex  = regexp.MustCompile(`(?:Writing|Compacting) large partition ([^/\s]+)/([^:\s]+):(.*?) \(([\d.]+)\s*([KMGT]i?B|bytes|B)?\)`)
+	hintsSourceRegex     = regexp.MustCompile(`(?i)hint`)
+	endpointRegex        = regexp.MustCompile(`(?:endpoint|host|to) /?([0-9a-fA-F.:]+[0-9a-fA-F])`)
+	gossipRegex          = regexp.MustCompile(`^InetAddress /?(\S+) is now (UP|DOWN)`)
+
+	sizeUnits = map[string]float64{
+		"KB": 1 << 10, "KiB": 1 << 10,
+		"MB": 1 << 20, "MiB": 1 << 20,
+		"GB": 1 << 30, "GiB": 1 << 30,
+		"TB": 1 << 40, "TiB": 1 << 40,
+	}
+)
+
+// logEntry is a line of the system.log.
+type logEntry struct {
+	Level     string
+	Thread    string
+	Timestamp string
+	Source    string
+	Message   string
+}
+
+func parseLogEntry(line string) (logEntry, bool) {
+	match := logLineRegex.FindStringSubmatch(line)
+	if match == nil {
+		return logEntry{}, false
+	}
+	return logEntry{Level: match[1], Thread: match[2], Timestamp: match[3], Source: match[4], Message: match[5]}, true
+}
+
+// parseLogEvent returns the event for the line of the system.log, or nil for the lines not reported: GC pauses
+// shorter than the threshold in milliseconds, and anything that isn't a GC pause, dropped messages or mutations,
+// tombstone thresholds, large partitions, hinted handoff warnings and errors, or gossip UP and DOWN transitions.
+func parseLogEvent(line string, gcPauseThreshold int) *event.Event {
+	entry, ok := parseLogEntry(line)
+	if !ok {
+		return nil
+	}
+
+	var eventType, summary string
+	attributes := make(map[string]interface{})
+	message := entry.Message
+
+	if match := gcPauseRegex.FindStringSubmatch(message); match != nil {
+		duration, _ := strconv.ParseInt(match[2], 10, 64)
+		if duration < int64(gcPauseThreshold) {
+			return nil
+		}
+		eventType = logEventGCPause
+		summary = fmt.Sprintf("%s GC pause of %dms", match[1], duration)
+		attributes["collector"] = match[1]
+		attributes["durationMs"] = duration
+	} else if match := droppedMessagesRegex.FindStringSubmatch(message); match != nil {
+		eventType = logEventDroppedMessages
+		summary = fmt.Sprintf("%s messages dropped", match[1])
+		attributes["verb"] = match[1]
+		attributes["intervalMs"], _ = strconv.ParseInt(match[2], 10, 64)
+		attributes["internalDropped"], _ = strconv.ParseInt(match[3], 10, 64)
+		attributes["crossNodeDropped"], _ = strconv.ParseInt(match[4], 10, 64)
+	} else if droppedMutationRegex.MatchString(message) {
+		eventType = logEventDroppedMutation
+		summary = "Mutation dropped"
+		setQueryTable(attributes, message)
+	} else if match := tombstoneWarnRegex.FindStringSubmatch(message); match != nil {
+		eventType = logEventTombstones
+		summary = "Tombstone warning threshold exceeded"
+		attributes["liveRows"], _ = strconv.ParseInt(match[1], 10, 64)
+		attributes["tombstones"], _ = strconv.ParseInt(match[2], 10, 64)
+		attributes["aborted"] = false
+		setQueryTable(attributes, match[3])
+	} else if match := tombstoneFailRegex.FindStringSubmatch(message); match != nil {
+		eventType = logEventTombstones
+		summary = "Tombstone failure threshold exceeded, query aborted"
+		attributes["tombstones"], _ = strconv.ParseInt(match[1], 10, 64)
+		attributes["aborted"] = true
+		setQueryTable(attributes, match[2])
+	} else if tombstoneRegex.MatchString(message) {
+		eventType = logEventTombstones
+		summary = "Tombstone threshold exceeded"
+		setQueryTable(attributes, message)
+	} else if match := largePartitionRegex.FindStringSubmatch(message); match != nil {
+		eventType = logEventLargePartition
+		summary = fmt.Sprintf("Large partition in %s.%s", match[1], match[2])
+		attributes["keyspace"] = match[1]
+		attributes["table"] = match[2]
+		attributes["partitionKey"] = match[3]
+		attributes["sizeBytes"] = parseLogSize(match[4], match[5])
+	} else if match := gossipRegex.FindStringSubmatch(message); match != nil {
+		eventType = logEventGossipTransition
+		summary = fmt.Sprintf("Node %s is now %s", match[1], match[2])
+		attributes["endpoint"] = match[1]
+		attributes["state"] = match[2]
+	} else if isHintedHandoffProblem(entry) {
+		eventType = logEventHintedHandoff
+		summary = "Hinted handoff " + strings.ToLower(entry.Level)
+		if match := endpointRegex.FindStringSubmatch(message); match != nil {
+			attributes["endpoint"] = match[1]
+		}
+	} else {
+		return nil
+	}
+
+	attributes["event.type"] = eventType
+	attributes["log.level"] = entry.Level
+	attributes["log.thread"] = entry.Thread
+	attributes["log.timestamp"] = entry.Timestamp
+	attributes["log.source"] = entry.Source
+	attributes["log.message"] = truncateString(message, maxLogMessageLength)
+	return event.NewWithAttributes(summary, logEventCategory, attributes)
+}
+
+// isHintedHandoffProblem reports the warnings and errors of the hints threads and classes, and the hints files
+// dispatched partially.
+func isHintedHandoffProblem(entry logEntry) bool {
+	if !hintsSourceRegex.MatchString(entry.Source + " " + entry.Thread) {
+		return false
+	}
+	return entry.Level == "WARN" || entry.Level == "ERROR" || strings.HasSuffix(entry.Message, "partially")
+}
+
+// setQueryTable sets the keyspace and table read by the query in the message, if any.
+func setQueryTable(attributes map[string]interface{}, message string) {
+	if match := queryTableRegex.FindStringSubmatch(message); match != nil {
+		attributes["keyspace"] = match[1]
+		attributes["table"] = match[2]
+	}
+}
+
+// parseLogSize converts the sizes logged by Cassandra, like '123.456MiB' or '123456789 bytes', to bytes.
+func parseLogSize(value, unit string) int64 {
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	if multiplier, ok := sizeUnits[unit]; ok {
+		size *= multiplier
+	}
+	return int64(size)
+}
+
+// logEventsCollector reports the events parsed from the lines appended to the system.log.
+type logEventsCollector struct {
+	tailer           *logTailer
+	gcPauseThreshold int
+}
+
+// openLogEvents returns the collector of the log events when enabled, or nil. Log events are only collected in
+// long-running mode, as the lines written between the executions would be missed otherwise.
+func openLogEvents() *logEventsCollector {
+	if !args.LogEvents {
+		return nil
+	}
+	if !args.LongRunning {
+		log.Warn("Log events are only collected in long-running mode, set LONG_RUNNING to enable them")
+		return nil
+	}
+
+	return &logEventsCollector{tailer: newLogTailer(args.SystemLogPath), gcPauseThreshold: args.GCPauseThreshold}
+}
+
+// collect adds the events of the lines appended to the log since the previous call to the entity. It does nothing
+// on a nil collector.
+func (c *logEventsCollector) collect(i *integration.Integration) {
+	if c == nil {
+		return
+	}
+
+	lines, err := c.tailer.poll()
+	if err != nil {
+		log.Warn("Failed to read the system log: %v", err)
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	e, err := entity(i)
+	if err != nil {
+		log.Error("Failed to create entity: %v", err)
+		return
+	}
+	for _, line := range lines {
+		evt := parseLogEvent(line, c.gcPauseThreshold)
+		if evt == nil {
+			continue
+		}
+		if err := e.AddEvent(evt); err != nil {
+			log.Debug("Failed to add log event: %v", err)
+		}
+	}
+}
+
+// Close stops following the log. It does nothing on a nil collector.
+func (c *logEventsCollector) Close() {
+	if c != nil {
+		c.tailer.Close()
+	}
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogEvent(t *testing.T) {
+	testCases := []struct {
+		name       string
+		line       string
+		summary    string
+		attributes map[string]interface{}
+	}{
+		{
+			name:       "gc pause",
+			line:       "WARN  [Service Thread] 2022-05-10 10:00:00,123 GCInspector.java:294 - G1 Young Generation GC in 1234ms.  G1 Eden Space: 1006632960 -> 0; G1 Old Gen: 2147483648 -> 2214592512",
+			summary:    "G1 Young Generation GC pause of 1234ms",
+			attributes: map[string]interface{}{"event.type": logEventGCPause, "collector": "G1 Young Generation", "durationMs": int64(1234)},
+		},
+		{
+			name:       "dropped messages",
+			line:       "INFO  [ScheduledTasks:1] 2022-05-10 10:00:00,123 MessagingMetrics.java:206 - MUTATION_REQ messages were dropped in last 5000 ms: 10 internal and 3 cross node. Mean internal dropped latency: 2730 ms and Mean cross-node dropped latency: 2731 ms",
+			summary:    "MUTATION_REQ messages dropped",
+			attributes: map[string]interface{}{"event.type": logEventDroppedMessages, "verb": "MUTATION_REQ", "intervalMs": int64(5000), "internalDropped": int64(10), "crossNodeDropped": int64(3)},
+		},
+		{
+			name:       "dropped mutation",
+			line:       "WARN  [MutationStage-2] 2022-05-10 10:00:00,123 AbstractLocalAwareExecutorService.java:166 - Dropping mutation for users.profiles, the mutation timed out",
+			summary:    "Mutation dropped",
+			attributes: map[string]interface{}{"event.type": logEventDroppedMutation},
+		},
+		{
+			name:       "tombstone warning",
+			line:       "WARN  [ReadStage-2] 2022-05-10 10:00:00,123 ReadCommand.java:606 - Read 0 live rows and 1001 tombstone cells for query SELECT * FROM users.profiles WHERE id = 1 LIMIT 5000; token 1234 (see tombstone_warn_threshold)",
+			summary:    "Tombstone warning threshold exceeded",
+			attributes: map[string]interface{}{"event.type": logEventTombstones, "liveRows": int64(0), "tombstones": int64(1001), "aborted": false, "keyspace": "users", "table": "profiles"},
+		},
+		{
+			name:       "tombstone failure",
+			line:       "ERROR [ReadStage-2] 2022-05-10 10:00:00,123 StorageProxy.java:2078 - Scanned over 100001 tombstones during query 'SELECT * FROM users.profiles WHERE id = 1 LIMIT 5000' (last scanned row token was 1234 and partion key was (1)); query aborted",
+			summary:    "Tombstone failure threshold exceeded, query aborted",
+			attributes: map[string]interface{}{"event.type": logEventTombstones, "tombstones": int64(100001), "aborted": true, "keyspace": "users", "table": "profiles"},
+		},
+		{
+			name:       "large partition",
+			line:       "WARN  [CompactionExecutor:4] 2022-05-10 10:00:00,123 BigTableWriter.java:274 - Writing large partition users/profiles:user42 (125.500MiB) to sstable /var/lib/cassandra/data/users/profiles-1/nb-5-big-Data.db",
+			summary:    "Large partition in users.profiles",
+			attributes: map[string]interface{}{"event.type": logEventLargePartition, "keyspace": "users", "table": "profiles", "partitionKey": "user42", "sizeBytes": int64(131596288)},
+		},
+		{
+			name:       "large partition in bytes",
+			line:       "WARN  [CompactionExecutor:4] 2022-05-10 10:00:00,123 BigTableWriter.java:184 - Compacting large partition users/profiles:user42 (131596288 bytes)",
+			summary:    "Large partition in users.profiles",
+			attributes: map[string]interface{}{"event.type": logEventLargePartition, "keyspace": "users", "table": "profiles", "partitionKey": "user42", "sizeBytes": int64(131596288)},
+		},
+		{
+			name:       "hints dispatched partially",
+			line:       "INFO  [HintsDispatcher:3] 2022-05-10 10:00:00,123 HintsDispatchExecutor.java:289 - Finished hinted handoff of file 5b4c1e7c-1f5a-4a5d-9c7e-2f1d6c0a9b3e-1652176800000-2.hints to endpoint /10.0.0.2:7000: 5b4c1e7c-1f5a-4a5d-9c7e-2f1d6c0a9b3e, partially",
+			summary:    "Hinted handoff info",
+			attributes: map[string]interface{}{"event.type": logEventHintedHandoff, "endpoint": "10.0.0.2:7000"},
+		},
+		{
+			name:       "hints error",
+			line:       "ERROR [HintsDispatcher:3] 2022-05-10 10:00:00,123 HintsDispatchExecutor.java:243 - Failed to dispatch hints file 5b4c1e7c-1f5a-4a5d-9c7e-2f1d6c0a9b3e-1652176800000-2.hints: file is corrupted",
+			summary:    "Hinted handoff error",
+			attributes: map[string]interface{}{"event.type": logEventHintedHandoff},
+		},
+		{
+			name:       "node down",
+			line:       "INFO  [GossipStage:1] 2022-05-10 10:00:00,123 Gossiper.java:1200 - InetAddress /10.0.0.2:7000 is now DOWN",
+			summary:    "Node 10.0.0.2:7000 is now DOWN",
+			attributes: map[string]interface{}{"event.type": logEventGossipTransition, "endpoint": "10.0.0.2:7000", "state": "DOWN"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			evt := parseLogEvent(tc.line, 1000)
+			require.NotNil(t, evt)
+			assert.Equal(t, tc.summary, evt.Summary)
+			assert.Equal(t, logEventCategory, evt.Category)
+			for key, value := range tc.attributes {
+				assert.Equal(t, value, evt.Attributes[key], key)
+			}
+			assert.Equal(t, "2022-05-10 10:00:00,123", evt.Attributes["log.timestamp"])
+		})
+	}
+}
+
+func TestParseLogEvent_LongMessage(t *testing.T) {
+	// Either message would be cut in the middle of a 2 bytes rune at one of the offsets.
+	for _, padding := range []string{"", "a"} {
+		line := "WARN  [MutationStage-2] 2022-05-10 10:00:00,123 AbstractLocalAwareExecutorService.java:166 - Dropping mutation for users.profiles, " + padding + strings.Repeat("é", 3000)
+
+		evt := parseLogEvent(line, 1000)
+		require.NotNil(t, evt)
+		message := evt.Attributes["log.message"].(string)
+		assert.True(t, utf8.ValidString(message))
+		assert.LessOrEqual(t, len(message), maxLogMessageLength)
+		assert.GreaterOrEqual(t, len(message), maxLogMessageLength-1)
+	}
+}
+
+func TestParseLogEvent_Ignored(t *testing.T) {
+	lines := []string{
+		"INFO  [Service Thread] 2022-05-10 10:00:00,123 GCInspector.java:294 - G1 Young Generation GC in 250ms.  G1 Eden Space: 1006632960 -> 0",
+		"INFO  [main] 2022-05-10 10:00:00,123 StorageService.java:1500 - JOINING: Finish joining ring",
+		"INFO  [HintsDispatcher:3] 2022-05-10 10:00:00,123 HintsDispatchExecutor.java:289 - Finished hinted handoff of file 1-2.hints to endpoint /10.0.0.2:7000: 1",
+		"\tat org.apache.cassandra.db.ReadCommand.executeLocally(ReadCommand.java:412)",
+		"",
+	}
+
+	for _, line := range lines {
+		assert.Nil(t, parseLogEvent(line, 1000), line)
+	}
+}
+
+func TestLogEventsCollector(t *testing.T) {
+	i := setupCollection(t)
+	args.LongRunning = true
+	args.LogEvents = true
+	args.SystemLogPath = path.Join(t.TempDir(), "system.log")
+	args.GCPauseThreshold = 200
+	require.NoError(t, os.WriteFile(args.SystemLogPath, []byte("INFO  [GossipStage:1] 2022-05-10 09:00:00,000 Gossiper.java:1200 - InetAddress /10.0.0.3:7000 is now UP\n"), 0o600))
+
+	collector := openLogEvents()
+	require.NotNil(t, collector)
+	defer collector.Close()
+
+	collector.collect(i)
+	assert.Empty(t, i.LocalEntity().Events, "the lines written before starting are skipped")
+
+	appendFile(t, args.SystemLogPath, "WARN  [Service Thread] 2022-05-10 10:00:00,123 GCInspector.java:294 - ParNew GC in 250ms.  CMS Old Gen: 1 -> 2\n"+
+		"INFO  [GossipStage:1] 2022-05-10 10:00:01,000 Gossiper.java:1200 - InetAddress /10.0.0.2:7000 is now DOWN\n")
+	collector.collect(i)
+
+	events := i.LocalEntity().Events
+	require.Len(t, events, 2)
+	assert.Equal(t, "ParNew GC pause of 250ms", events[0].Summary)
+	assert.Equal(t, "Node 10.0.0.2:7000 is now DOWN", events[1].Summary)
+}
+
+func TestOpenLogEvents_NotLongRunning(t *testing.T) {
+	setupCollection(t)
+	args.LogEvents = true
+
+	assert.Nil(t, openLogEvents())
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// logTailer follows a log file across rotations, returning the lines appended since the previous poll.
+type logTailer struct {
+	path string
+	file *os.File
+	info os.FileInfo
+	// partial is the last line read, until its newline is written.
+	partial []byte
+	// started is set after the first poll, which skips the lines already in the file.
+	started bool
+}
+
+func newLogTailer(path string) *logTailer {
+	return &logTailer{path: path}
+}
+
+// poll returns the complete lines appended to the file. When the file is rotated, the rest of the previous one is
+// read before following the new one from its beginning, and when it is truncated in place it is read again from
+// the beginning.
+func (t *logTailer) poll() ([]string, error) {
+	if t.file == nil {
+		if err := t.open(); err != nil {
+			return nil, err
+		}
+		if t.file == nil {
+			return nil, nil
+		}
+	}
+
+	lines, err := t.readLines()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(t.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Rotated, but the new file is not created yet.
+		return lines, nil
+	}
+	if err != nil {
+		return lines, fmt.Errorf("failed to stat log file %s: %w", t.path, err)
+	}
+
+	switch {
+	case !os.SameFile(info, t.info):
+		if len(t.partial) > 0 {
+			lines = append(lines, string(t.partial))
+		}
+		t.Close()
+		if err := t.open(); err != nil {
+			return lines, err
+		}
+	case info.Size() < t.offset():
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return lines, fmt.Errorf("failed to rewind truncated log file %s: %w", t.path, err)
+		}
+		t.partial = nil
+	default:
+		return lines, nil
+	}
+
+	rotated, err := t.readLines()
+	return append(lines, rotated...), err
+}
+
+// open opens the file, at its end on the first poll. A missing file is not an error, it is opened once created.
+func (t *logTailer) open() error {
+	skip := !t.started
+	t.started = true
+
+	file, err := os.Open(t.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %w", t.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file %s: %w", t.path, err)
+	}
+	if skip {
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to seek log file %s: %w", t.path, err)
+		}
+	}
+
+	t.file, t.info = file, info
+	return nil
+}
+
+func (t *logTailer) readLines() ([]string, error) {
+	content, err := io.ReadAll(t.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log file %s: %w", t.path, err)
+	}
+
+	content = append(t.partial, content...)
+	var lines []string
+	for {
+		idx := bytes.IndexByte(content, '\n')
+		if idx < 0 {
+			break
+		}
+		lines = append(lines, string(bytes.TrimSuffix(content[:idx], []byte("\r"))))
+		content = content[idx+1:]
+	}
+	t.partial = append([]byte(nil), content...)
+	return lines, nil
+}
+
+// offset is the position read up to in the file, the size it had when last read.
+func (t *logTailer) offset() int64 {
+	position, err := t.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	return position
+}
+
+// Close closes the file being followed.
+func (t *logTailer) Close() {
+	if t.file != nil {
+		t.file.Close()
+	}
+	t.file, t.info, t.partial = nil, nil, nil
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendFile(t *testing.T, name, content string) {
+	t.Helper()
+
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+}
+
+func TestLogTailer_Poll(t *testing.T) {
+	logPath := path.Join(t.TempDir(), "system.log")
+	appendFile(t, logPath, "before start\n")
+
+	tailer := newLogTailer(logPath)
+	defer tailer.Close()
+
+	lines, err := tailer.poll()
+	require.NoError(t, err)
+	assert.Empty(t, lines)
+
+	appendFile(t, logPath, "first\nsecond\nthi")
+	lines, err = tailer.poll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, lines)
+
+	appendFile(t, logPath, "rd\r\n")
+	lines, err = tailer.poll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"third"}, lines)
+}
+
+func TestLogTailer_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	logPath := path.Join(dir, "system.log")
+	appendFile(t, logPath, "")
+
+	tailer := newLogTailer(logPath)
+	defer tailer.Close()
+	_, err := tailer.poll()
+	require.NoError(t, err)
+
+	appendFile(t, logPath, "old\n")
+	require.NoError(t, os.Rename(logPath, path.Join(dir, "system.log.1.zip")))
+
+	lines, err := tailer.poll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old"}, lines, "the rotated file is read until the new one is created")
+
+	appendFile(t, path.Join(dir, "system.log.1.zip"), "last\n")
+	appendFile(t, logPath, "new\n")
+	lines, err = tailer.poll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"last", "new"}, lines)
+
+	appendFile(t, logPath, "newer\n")
+	lines, err = tailer.poll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"newer"}, lines)
+}
+
+func TestLogTailer_Truncation(t *testing.T) {
+	logPath := path.Join(t.TempDir(), "system.log")
+	appendFile(t, logPath, "a long line before the truncation\n")
+
+	tailer := newLogTailer(logPath)
+	defer tailer.Close()
+	_, err := tailer.poll()
+	require.NoError(t, err)
+
+	require.NoError(t, os.Truncate(logPath, 0))
+	appendFile(t, logPath, "after\n")
+
+	lines, err := tailer.poll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"after"}, lines)
+}
+
+func TestLogTailer_MissingFile(t *testing.T) {
+	logPath := path.Join(t.TempDir(), "system.log")
+
+	tailer := newLogTailer(logPath)
+	defer tailer.Close()
+
+	lines, err := tailer.poll()
+	require.NoError(t, err)
+	assert.Empty(t, lines)
+
+	appendFile(t, logPath, "created\n")
+	lines, err = tailer.poll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"created"}, lines)
+}
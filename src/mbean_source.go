@@ -0,0 +1,45 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import "github.com/newrelic/nrjmx/gojmx"
+
+// MBeanSource abstracts the backend used by the collector to query the MBeans. The nrjmx gojmx.Client is the
+// default implementation, other backends only have to provide the same query semantics.
+type MBeanSource interface {
+	// QueryMBeanNames returns all the mBeans names matching the glob pattern.
+	QueryMBeanNames(mBeanGlobPattern string) ([]string, error)
+	// QueryMBeanAttributes returns the attribute values for all the mBeans matching the pattern.
+	QueryMBeanAttributes(mBeanNamePattern string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error)
+	// GetMBeanAttributes returns the attribute values for a single mBean.
+	GetMBeanAttributes(mBeanName string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error)
+	// IsRunning reports if the source is still able to serve queries.
+	IsRunning() bool
+	// Close releases the connection with the endpoint.
+	Close() error
+}
+
+// internalStatsSource is implemented by the MBeanSources able to report their internal query stats.
+type internalStatsSource interface {
+	GetInternalStats() (gojmx.InternalStatsList, error)
+}
+
+// attributesResult keeps the outcome of an attribute query.
+type attributesResult struct {
+	attributes []*gojmx.AttributeResponse
+	err        error
+}
+
+// attributesBulkQuerier is implemented by the MBeanSources able to perform several attribute queries in a single
+// round-trip. The results are returned in the same order of the queries.
+type attributesBulkQuerier interface {
+	QueryMBeanAttributesBulk(queries []Query) []attributesResult
+}
+
+var (
+	_ MBeanSource         = (*gojmx.Client)(nil)
+	_ internalStatsSource = (*gojmx.Client)(nil)
+)
@@ -11,20 +11,39 @@ import (
 	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
 )
 
+// Event types of the samples reported by the integration.
+const (
+	cassandraSample      = "CassandraSample"
+	columnFamilySample   = "CassandraColumnFamilySample"
+	threadPoolSample     = "CassandraThreadPoolSample"
+	droppedMessageSample = "CassandraDroppedMessageSample"
+	hintsSample          = "CassandraHintsSample"
+)
+
 // Definitions struct will contain the metrics that have to be collected.
 type Definitions struct {
-	Common              []Query `yaml:"common"`
-	Metrics             []Query `yaml:"metrics"`
-	ColumnFamilyMetrics []Query `yaml:"column_family_metrics"`
+	Common                []Query `yaml:"common,omitempty"`
+	Metrics               []Query `yaml:"metrics,omitempty"`
+	ColumnFamilyMetrics   []Query `yaml:"column_family_metrics,omitempty"`
+	ThreadPoolMetrics     []Query `yaml:"thread_pool_metrics,omitempty"`
+	DroppedMessageMetrics []Query `yaml:"dropped_message_metrics,omitempty"`
+	HintsMetrics          []Query `yaml:"hints_metrics,omitempty"`
+
+	// columnFamilyFilter is set when the filtering rules are scoped to keyspaces or tables, so the column family
+	// metrics are filtered for each table when collecting.
+	columnFamilyFilter *FilteringConfig
 }
 
 // NewDefinitions returns the definitions of the metrics that have to be collected.
 // If extra filtering configuration is provided by the agent, that will be applied to filter the result.
 func NewDefinitions() Definitions {
 	return Definitions{
-		Common:              commonDefinitions,
-		Metrics:             metricDefinitions,
-		ColumnFamilyMetrics: columnFamilyDefinitions,
+		Common:                commonDefinitions,
+		Metrics:               metricDefinitions,
+		ColumnFamilyMetrics:   columnFamilyDefinitions,
+		ThreadPoolMetrics:     threadPoolDefinitions,
+		DroppedMessageMetrics: droppedMessageDefinitions,
+		HintsMetrics:          hintsDefinitions,
 	}
 }
 
@@ -35,24 +54,25 @@ func (d *Definitions) Filter(config FilteringConfig) {
 		return
 	}
 
-	d.Common = filterQueries(d.Common, config)
-	d.Metrics = filterQueries(d.Metrics, config)
-	d.ColumnFamilyMetrics = filterQueries(d.ColumnFamilyMetrics, config)
+	// Common metrics are reported on every sample, so only rules without a sample apply to them.
+	d.Common = filterQueries(d.Common, config, "")
+	d.Metrics = filterQueries(d.Metrics, config, cassandraSample)
+	d.ThreadPoolMetrics = filterQueries(d.ThreadPoolMetrics, config, threadPoolSample)
+	d.DroppedMessageMetrics = filterQueries(d.DroppedMessageMetrics, config, droppedMessageSample)
+	d.HintsMetrics = filterQueries(d.HintsMetrics, config, hintsSample)
+
+	if config.hasTableRules() {
+		d.columnFamilyFilter = &config
+		return
+	}
+	d.ColumnFamilyMetrics = filterQueries(d.ColumnFamilyMetrics, config, columnFamilySample)
 }
 
-func filterQueries(queries []Query, config FilteringConfig) []Query {
+func filterQueries(queries []Query, config FilteringConfig, sample string) []Query {
 	var result []Query
 	// MetricNameList Metric Definitions specified in config.
 	for _, query := range queries {
-		attributes := query.Attributes
-		query.Attributes = []Attribute{}
-
-		for _, attribute := range attributes {
-			if config.IsFiltered(attribute) {
-				continue
-			}
-			query.Attributes = append(query.Attributes, attribute)
-		}
+		query.Attributes = filterAttributes(query.Attributes, config, filterScope{Sample: sample, MBean: query.MBean})
 
 		if len(query.Attributes) > 0 {
 			result = append(result, query)
@@ -61,6 +81,39 @@ func filterQueries(queries []Query, config FilteringConfig) []Query {
 	return result
 }
 
+// filterAttributes returns the attributes not filtered by the config in the scope.
+func filterAttributes(attributes []Attribute, config FilteringConfig, scope filterScope) []Attribute {
+	result := []Attribute{}
+	for _, attribute := range attributes {
+		if config.IsFiltered(scope, attribute) {
+			continue
+		}
+		result = append(result, attribute)
+	}
+	return result
+}
+
+// legacyHistogramAttributes are the histogram percentiles that are counts, as they were reported when all the
+// percentiles were converted from microseconds. They are reported unless LEGACY_HISTOGRAM_UNITS is false.
+var legacyHistogramAttributes = map[string][]Attribute{
+	"org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=SSTablesPerReadHistogram": {
+		{MBeanAttribute: "50thPercentile", Alias: "db.SSTablesPerRead50thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "75thPercentile", Alias: "db.SSTablesPerRead75thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "95thPercentile", Alias: "db.SSTablesPerRead95thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "98thPercentile", Alias: "db.SSTablesPerRead98thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "99thPercentile", Alias: "db.SSTablesPerRead99thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "999thPercentile", Alias: "db.SSTablesPerRead999thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+	},
+	"org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=TombstoneScannedHistogram": {
+		{MBeanAttribute: "50thPercentile", Alias: "db.tombstoneScannedHistogram50thPercentile", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "75thPercentile", Alias: "db.tombstoneScannedHistogram75thPercentile", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "95thPercentile", Alias: "db.tombstoneScannedHistogram95thPercentile", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "98thPercentile", Alias: "db.tombstoneScannedHistogram98thPercentile", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "99thPercentile", Alias: "db.tombstoneScannedHistogram99thPercentile", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		{MBeanAttribute: "999thPercentile", Alias: "db.tombstoneScannedHistogram999thPercentile", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+	},
+}
+
 // commonDefinitions are metric definitions that are common for both CassandraColumnFamilySample and CassandraSample.
 var commonDefinitions = []Query{
 	{
@@ -90,42 +143,42 @@ var columnFamilyDefinitions = []Query{
 	{
 		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=SSTablesPerReadHistogram",
 		Attributes: []Attribute{
-			{MBeanAttribute: "75thPercentile", Alias: "db.SSTablesPerRead75thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "999thPercentile", Alias: "db.SSTablesPerRead999thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "50thPercentile", Alias: "db.SSTablesPerRead50thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "95thPercentile", Alias: "db.SSTablesPerRead95thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "98thPercentile", Alias: "db.SSTablesPerRead98thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "99thPercentile", Alias: "db.SSTablesPerRead99thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "75thPercentile", Alias: "db.SSTablesPerRead75thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "999thPercentile", Alias: "db.SSTablesPerRead999thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "50thPercentile", Alias: "db.SSTablesPerRead50thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "95thPercentile", Alias: "db.SSTablesPerRead95thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "98thPercentile", Alias: "db.SSTablesPerRead98thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "99thPercentile", Alias: "db.SSTablesPerRead99thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
 		},
 	},
 	{
 		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=LiveDiskSpaceUsed",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Count", Alias: "db.liveDiskSpaceUsedBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Count", Alias: "db.liveDiskSpaceUsedBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
 		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=ReadLatency",
 		Attributes: []Attribute{
-			{MBeanAttribute: "999thPercentile", Alias: "query.readLatency999thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "50thPercentile", Alias: "query.readLatency50thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "95thPercentile", Alias: "query.readLatency95thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "99thPercentile", Alias: "query.readLatency99thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "999thPercentile", Alias: "query.readLatency999thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "50thPercentile", Alias: "query.readLatency50thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "95thPercentile", Alias: "query.readLatency95thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "99thPercentile", Alias: "query.readLatency99thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
 			{MBeanAttribute: "OneMinuteRate", Alias: "query.readRequestsPerSecond", MetricType: metric.GAUGE},
-			{MBeanAttribute: "75thPercentile", Alias: "query.readLatency75thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "98thPercentile", Alias: "query.readLatency98thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "75thPercentile", Alias: "query.readLatency75thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "98thPercentile", Alias: "query.readLatency98thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
 		},
 	},
 	{
 		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=WriteLatency",
 		Attributes: []Attribute{
-			{MBeanAttribute: "999thPercentile", Alias: "query.writeLatency999thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "98thPercentile", Alias: "query.writeLatency98thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "95thPercentile", Alias: "query.writeLatency95thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "999thPercentile", Alias: "query.writeLatency999thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "98thPercentile", Alias: "query.writeLatency98thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "95thPercentile", Alias: "query.writeLatency95thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
 			{MBeanAttribute: "OneMinuteRate", Alias: "query.writeRequestsPerSecond", MetricType: metric.GAUGE},
-			{MBeanAttribute: "75thPercentile", Alias: "query.writeLatency75thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "99thPercentile", Alias: "query.writeLatency99thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "50thPercentile", Alias: "query.writeLatency50thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "75thPercentile", Alias: "query.writeLatency75thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "99thPercentile", Alias: "query.writeLatency99thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "50thPercentile", Alias: "query.writeLatency50thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
 		},
 	},
 	{
@@ -137,26 +190,79 @@ var columnFamilyDefinitions = []Query{
 	{
 		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=AllMemtablesHeapSize",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Value", Alias: "db.allMemtablesOnHeapSizeBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Value", Alias: "db.allMemtablesOnHeapSizeBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
 		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=AllMemtablesOffHeapSize",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Value", Alias: "db.allMemtablesOffHeapSizeBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Value", Alias: "db.allMemtablesOffHeapSizeBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 
 	{
 		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=TombstoneScannedHistogram",
 		Attributes: []Attribute{
-			{MBeanAttribute: "75thPercentile", Alias: "db.tombstoneScannedHistogram75thPercentile", MetricType: metric.GAUGE},
+			{MBeanAttribute: "75thPercentile", Alias: "db.tombstoneScannedHistogram75thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
 			{MBeanAttribute: "Count", Alias: "db.tombstoneScannedHistogramCount", MetricType: metric.GAUGE},
-			{MBeanAttribute: "95thPercentile", Alias: "db.tombstoneScannedHistogram95thPercentile", MetricType: metric.GAUGE},
-			{MBeanAttribute: "999thPercentile", Alias: "db.tombstoneScannedHistogram999thPercentile", MetricType: metric.GAUGE},
-			{MBeanAttribute: "99thPercentile", Alias: "db.tombstoneScannedHistogram99thPercentile", MetricType: metric.GAUGE},
-			{MBeanAttribute: "50thPercentile", Alias: "db.tombstoneScannedHistogram50thPercentile", MetricType: metric.GAUGE},
-			{MBeanAttribute: "98thPercentile", Alias: "db.tombstoneScannedHistogram98thPercentile", MetricType: metric.GAUGE},
+			{MBeanAttribute: "95thPercentile", Alias: "db.tombstoneScannedHistogram95thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "999thPercentile", Alias: "db.tombstoneScannedHistogram999thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "99thPercentile", Alias: "db.tombstoneScannedHistogram99thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "50thPercentile", Alias: "db.tombstoneScannedHistogram50thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "98thPercentile", Alias: "db.tombstoneScannedHistogram98thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=LiveScannedHistogram",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Count", Alias: "db.liveScannedHistogramCount", MetricType: metric.GAUGE},
+			{MBeanAttribute: "50thPercentile", Alias: "db.liveScannedHistogram50thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "75thPercentile", Alias: "db.liveScannedHistogram75thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "95thPercentile", Alias: "db.liveScannedHistogram95thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "98thPercentile", Alias: "db.liveScannedHistogram98thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "99thPercentile", Alias: "db.liveScannedHistogram99thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "999thPercentile", Alias: "db.liveScannedHistogram999thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=TombstoneFailures",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Count", Alias: "db.tombstoneFailures", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=TombstoneWarnings",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Count", Alias: "db.tombstoneWarnings", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=EstimatedPartitionSizeHistogram",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Value", Alias: "db.partitionSize50thPercentileBytes", MetricType: metric.GAUGE, Unit: unitPartitionSizeBuckets},
+			{MBeanAttribute: "Value", Alias: "db.partitionSize75thPercentileBytes", MetricType: metric.GAUGE, Unit: unitPartitionSizeBuckets},
+			{MBeanAttribute: "Value", Alias: "db.partitionSize95thPercentileBytes", MetricType: metric.GAUGE, Unit: unitPartitionSizeBuckets},
+			{MBeanAttribute: "Value", Alias: "db.partitionSize98thPercentileBytes", MetricType: metric.GAUGE, Unit: unitPartitionSizeBuckets},
+			{MBeanAttribute: "Value", Alias: "db.partitionSize99thPercentileBytes", MetricType: metric.GAUGE, Unit: unitPartitionSizeBuckets},
+			{MBeanAttribute: "Value", Alias: "db.partitionsOverWarningThreshold", MetricType: metric.GAUGE, Unit: unitPartitionSizeBuckets},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=MinPartitionSize",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Value", Alias: "db.partitionSizeMinBytes", MetricType: metric.GAUGE, Unit: unitBytes},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=MeanPartitionSize",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Value", Alias: "db.partitionSizeMeanBytes", MetricType: metric.GAUGE, Unit: unitBytes},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=Table,keyspace=*,scope=*,name=MaxPartitionSize",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Value", Alias: "db.partitionSizeMaxBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
@@ -223,6 +329,138 @@ var columnFamiliesSampleAttributes = []SampleAttribute{
 	},
 }
 
+// threadPoolDefinitions are the CassandraThreadPoolSample metrics definition.
+// The path and scope wildcards are expanded at collection time so new pools are reported as they appear.
+var threadPoolDefinitions = []Query{
+	{
+		MBean: "org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=ActiveTasks",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Value", Alias: "activeTasks", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=PendingTasks",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Value", Alias: "pendingTasks", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=CompletedTasks",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Value", Alias: "completedTasks", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=CurrentlyBlockedTasks",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Count", Alias: "currentlyBlockedTasks", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=TotalBlockedTasks",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Count", Alias: "totalBlockedTasks", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=ThreadPools,path=*,scope=*,name=MaxPoolSize",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Value", Alias: "maxPoolSize", MetricType: metric.GAUGE},
+		},
+	},
+}
+
+// threadPoolSampleAttributes are NR extra attributes to make CassandraThreadPoolSample unique.
+var threadPoolSampleAttributes = []SampleAttribute{
+	{
+		Key:        "path",
+		Alias:      "path",
+		MetricType: metric.ATTRIBUTE,
+	},
+	{
+		Key:        "pool",
+		Alias:      "pool",
+		MetricType: metric.ATTRIBUTE,
+	},
+}
+
+// droppedMessageDefinitions are the CassandraDroppedMessageSample metrics definition.
+// The scope wildcard is expanded at collection time so new message verbs are reported as they appear.
+var droppedMessageDefinitions = []Query{
+	{
+		MBean: "org.apache.cassandra.metrics:type=DroppedMessage,scope=*,name=Dropped",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Count", Alias: "droppedMessagesPerSecond", MetricType: metric.RATE},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=DroppedMessage,scope=*,name=InternalDroppedLatency",
+		Attributes: []Attribute{
+			{MBeanAttribute: "50thPercentile", Alias: "internalDroppedLatency50thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "75thPercentile", Alias: "internalDroppedLatency75thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "95thPercentile", Alias: "internalDroppedLatency95thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "98thPercentile", Alias: "internalDroppedLatency98thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "99thPercentile", Alias: "internalDroppedLatency99thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "999thPercentile", Alias: "internalDroppedLatency999thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=DroppedMessage,scope=*,name=CrossNodeDroppedLatency",
+		Attributes: []Attribute{
+			{MBeanAttribute: "50thPercentile", Alias: "crossNodeDroppedLatency50thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "75thPercentile", Alias: "crossNodeDroppedLatency75thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "95thPercentile", Alias: "crossNodeDroppedLatency95thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "98thPercentile", Alias: "crossNodeDroppedLatency98thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "99thPercentile", Alias: "crossNodeDroppedLatency99thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "999thPercentile", Alias: "crossNodeDroppedLatency999thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+		},
+	},
+}
+
+// droppedMessageSampleAttributes are NR extra attributes to make CassandraDroppedMessageSample unique.
+var droppedMessageSampleAttributes = []SampleAttribute{
+	{
+		Key:        "verb",
+		Alias:      "verb",
+		MetricType: metric.ATTRIBUTE,
+	},
+}
+
+// hintsDefinitions are the CassandraHintsSample metrics definition, from the MBeans Cassandra registers for each
+// endpoint hints are stored for. The endpoint wildcard is expanded at collection time.
+var hintsDefinitions = []Query{
+	{
+		MBean: "org.apache.cassandra.metrics:type=HintedHandOffManager,name=Hints_created-*",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Count", Alias: "hints.createdPerSecond", MetricType: metric.RATE},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.metrics:type=HintsService,name=Hint_delays-*",
+		Attributes: []Attribute{
+			{MBeanAttribute: "Count", Alias: "hints.succeededPerSecond", MetricType: metric.RATE},
+			{MBeanAttribute: "50thPercentile", Alias: "hints.delay50thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "75thPercentile", Alias: "hints.delay75thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "95thPercentile", Alias: "hints.delay95thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "98thPercentile", Alias: "hints.delay98thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "99thPercentile", Alias: "hints.delay99thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "999thPercentile", Alias: "hints.delay999thPercentileMilliseconds", MetricType: metric.GAUGE},
+		},
+	},
+}
+
+// hintsSampleAttributes are NR extra attributes to make CassandraHintsSample unique, and the pending hints files
+// read from the system_views.pending_hints table.
+var hintsSampleAttributes = []SampleAttribute{
+	{Key: "endpoint", Alias: "endpoint", MetricType: metric.ATTRIBUTE},
+	{Key: "host_id", Alias: "endpoint.hostId", MetricType: metric.ATTRIBUTE},
+	{Key: "dc", Alias: "endpoint.datacenter", MetricType: metric.ATTRIBUTE},
+	{Key: "rack", Alias: "endpoint.rack", MetricType: metric.ATTRIBUTE},
+	{Key: "status", Alias: "endpoint.status", MetricType: metric.ATTRIBUTE},
+	{Key: "files", Alias: "hints.pendingFiles", MetricType: metric.GAUGE},
+	{Key: "total_size", Alias: "hints.pendingFilesSizeBytes", MetricType: metric.GAUGE},
+}
+
 // metricDefinitions are the metric definitions for the CassandraSample.
 var metricDefinitions = []Query{
 	{
@@ -450,7 +688,7 @@ var metricDefinitions = []Query{
 	{
 		MBean: "org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Size",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Value", Alias: "db.keyCacheSizeBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Value", Alias: "db.keyCacheSizeBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
@@ -492,7 +730,7 @@ var metricDefinitions = []Query{
 	{
 		MBean: "org.apache.cassandra.metrics:type=Table,name=AllMemtablesOffHeapSize",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Value", Alias: "db.allMemtablesOffHeapSizeBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Value", Alias: "db.allMemtablesOffHeapSizeBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
@@ -517,19 +755,19 @@ var metricDefinitions = []Query{
 		MBean: "org.apache.cassandra.metrics:type=ClientRequest,scope=Read,name=Latency",
 		Attributes: []Attribute{
 			{MBeanAttribute: "OneMinuteRate", Alias: "query.readRequestsPerSecond", MetricType: metric.GAUGE},
-			{MBeanAttribute: "98thPercentile", Alias: "query.readLatency98thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "98thPercentile", Alias: "query.readLatency98thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
 
-			{MBeanAttribute: "50thPercentile", Alias: "query.readLatency50thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "999thPercentile", Alias: "query.readLatency999thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "99thPercentile", Alias: "query.readLatency99thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "75thPercentile", Alias: "query.readLatency75thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "95thPercentile", Alias: "query.readLatency95thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "50thPercentile", Alias: "query.readLatency50thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "999thPercentile", Alias: "query.readLatency999thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "99thPercentile", Alias: "query.readLatency99thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "75thPercentile", Alias: "query.readLatency75thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "95thPercentile", Alias: "query.readLatency95thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
 		},
 	},
 	{
 		MBean: "org.apache.cassandra.metrics:type=Table,name=AllMemtablesHeapSize",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Value", Alias: "db.allMemtablesOnHeapSizeBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Value", Alias: "db.allMemtablesOnHeapSizeBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
@@ -553,7 +791,7 @@ var metricDefinitions = []Query{
 	{
 		MBean: "org.apache.cassandra.metrics:type=Cache,scope=RowCache,name=Capacity",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Value", Alias: "db.rowCacheCapacityBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Value", Alias: "db.rowCacheCapacityBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
@@ -721,7 +959,7 @@ var metricDefinitions = []Query{
 	{
 		MBean: "org.apache.cassandra.metrics:type=Cache,scope=RowCache,name=Size",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Value", Alias: "db.rowCacheSizeBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Value", Alias: "db.rowCacheSizeBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
@@ -961,18 +1199,18 @@ var metricDefinitions = []Query{
 	{
 		MBean: "org.apache.cassandra.metrics:type=Storage,name=Load",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Count", Alias: "db.loadBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Count", Alias: "db.loadBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
 		MBean: "org.apache.cassandra.metrics:type=ClientRequest,scope=Write,name=Latency",
 		Attributes: []Attribute{
-			{MBeanAttribute: "999thPercentile", Alias: "query.writeLatency999thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "75thPercentile", Alias: "query.writeLatency75thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "95thPercentile", Alias: "query.writeLatency95thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "98thPercentile", Alias: "query.writeLatency98thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "50thPercentile", Alias: "query.writeLatency50thPercentileMilliseconds", MetricType: metric.GAUGE},
-			{MBeanAttribute: "99thPercentile", Alias: "query.writeLatency99thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "999thPercentile", Alias: "query.writeLatency999thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "75thPercentile", Alias: "query.writeLatency75thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "95thPercentile", Alias: "query.writeLatency95thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "98thPercentile", Alias: "query.writeLatency98thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "50thPercentile", Alias: "query.writeLatency50thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
+			{MBeanAttribute: "99thPercentile", Alias: "query.writeLatency99thPercentileMilliseconds", MetricType: metric.GAUGE, Unit: unitMicroseconds},
 			{MBeanAttribute: "OneMinuteRate", Alias: "query.writeRequestsPerSecond", MetricType: metric.GAUGE},
 		},
 	},
@@ -991,7 +1229,7 @@ var metricDefinitions = []Query{
 	{
 		MBean: "org.apache.cassandra.metrics:type=CommitLog,name=TotalCommitLogSize",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Value", Alias: "db.commitLogTotalSizeBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Value", Alias: "db.commitLogTotalSizeBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
@@ -1021,7 +1259,7 @@ var metricDefinitions = []Query{
 	{
 		MBean: "org.apache.cassandra.metrics:type=Cache,scope=KeyCache,name=Capacity",
 		Attributes: []Attribute{
-			{MBeanAttribute: "Value", Alias: "db.keyCacheCapacityBytes", MetricType: metric.GAUGE},
+			{MBeanAttribute: "Value", Alias: "db.keyCacheCapacityBytes", MetricType: metric.GAUGE, Unit: unitBytes},
 		},
 	},
 	{
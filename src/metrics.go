@@ -8,6 +8,8 @@ package main
 import (
 	"fmt"
 	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/newrelic/nrjmx/gojmx"
 
@@ -19,8 +21,14 @@ var (
 	// columnFamilyRegex matches the keyspace name and the scope.
 	columnFamilyRegex = regexp.MustCompile("keyspace=(.*),scope=(.*?),")
 
-	// percentileRegex is used to detect percentile mBean attributes.
-	percentileRegex = regexp.MustCompile("attr=.*Percentile")
+	// threadPoolRegex matches the thread pool path and the pool name (scope).
+	threadPoolRegex = regexp.MustCompile("path=(.*?),scope=(.*?),")
+
+	// droppedMessageRegex matches the dropped message verb (scope).
+	droppedMessageRegex = regexp.MustCompile("type=DroppedMessage,scope=(.*?),")
+
+	// hintsEndpointRegex matches the endpoint of the per-endpoint hints metrics, after the dash of the name.
+	hintsEndpointRegex = regexp.MustCompile("type=(?:HintedHandOffManager|HintsService),name=[A-Za-z_]+-([^,]*)")
 
 	// filteredKeyspace set used to match internal keyspace that should not be reported.
 	filteredKeyspace = map[string]struct{}{
@@ -33,14 +41,35 @@ var (
 	}
 )
 
+// attributesFetcher returns a function to retrieve the attributes of the query at the given index. When the
+// client supports bulk queries all of them are performed at once, otherwise each one is queried on demand so
+// the caller can stop at the first connection error.
+func attributesFetcher(client MBeanSource, queries []Query, query func(string, ...string) ([]*gojmx.AttributeResponse, error)) func(int) ([]*gojmx.AttributeResponse, error) {
+	bulkClient, ok := client.(attributesBulkQuerier)
+	if !ok {
+		return func(i int) ([]*gojmx.AttributeResponse, error) {
+			return query(queries[i].MBean, queries[i].GetAttributeNames()...)
+		}
+	}
+
+	var results []attributesResult
+	return func(i int) ([]*gojmx.AttributeResponse, error) {
+		if results == nil {
+			results = bulkClient.QueryMBeanAttributesBulk(queries)
+		}
+		return results[i].attributes, results[i].err
+	}
+}
+
 // getMetrics will gather all node level metrics and return them as a map.
-func getMetrics(client *gojmx.Client, queryConfig []Query) (map[string]interface{}, error) {
+func getMetrics(client MBeanSource, queryConfig []Query) (map[string]interface{}, error) {
 	metrics := make(map[string]interface{})
+	fetch := attributesFetcher(client, queryConfig, client.QueryMBeanAttributes)
 
-	for _, query := range queryConfig {
+	for idx, query := range queryConfig {
 		attrNames := query.GetAttributeNames()
 
-		results, err := client.QueryMBeanAttributes(query.MBean, attrNames...)
+		results, err := fetch(idx)
 		if err != nil {
 			if jmxErr, ok := gojmx.IsJMXError(err); ok {
 				log.Debug("Failed to get attributes: %s, for mBeanName %s: %v", attrNames, query.MBean, jmxErr)
@@ -64,18 +93,21 @@ func getMetrics(client *gojmx.Client, queryConfig []Query) (map[string]interface
 
 // getMetrics will gather all keyspace level metrics and return them as a map that
 // will contain maps for each <keyspace>.<columnFamily> found while inspecting JMX metrics.
-func getColumnFamilyMetrics(client *gojmx.Client, queryConfig []Query) (map[string]map[string]interface{}, error) {
+// When set, the filter is applied to the attributes of each column family.
+func getColumnFamilyMetrics(client MBeanSource, queryConfig []Query, filter *FilteringConfig) (map[string]map[string]interface{}, error) {
 	columnFamilyMetrics := make(map[string]map[string]interface{})
 
-	columnFamilyQueryConfig, err := getColumnFamilyQueries(client, queryConfig)
+	columnFamilyQueryConfig, err := getColumnFamilyQueries(client, queryConfig, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch 'column-family' metrics: %w", err)
 	}
 
-	for _, query := range columnFamilyQueryConfig {
+	fetch := attributesFetcher(client, columnFamilyQueryConfig, client.GetMBeanAttributes)
+
+	for idx, query := range columnFamilyQueryConfig {
 		attrNames := query.GetAttributeNames()
 
-		results, err := client.GetMBeanAttributes(query.MBean, attrNames...)
+		results, err := fetch(idx)
 		if err != nil {
 			if jmxErr, ok := gojmx.IsJMXError(err); ok {
 				log.Debug("Failed to get 'column-family' attributes: %s, for mBeanName %s: %v", attrNames, query.MBean, jmxErr)
@@ -111,12 +143,83 @@ func getColumnFamilyMetrics(client *gojmx.Client, queryConfig []Query) (map[stri
 	return columnFamilyMetrics, nil
 }
 
+// getScopedMetrics will gather the metrics of wildcard queries whose MBean names are split into samples by the
+// scopeRegex capture groups (e.g. thread pools or dropped message verbs). It returns a map that will contain maps
+// for each scope found while inspecting JMX metrics, with the captured values stored under scopeKeys.
+// The attribute names are keyed back to the wildcard query so populateMetrics can match them with the definitions.
+func getScopedMetrics(client MBeanSource, queryConfig []Query, scopeRegex *regexp.Regexp, scopeKeys ...string) (map[string]map[string]interface{}, error) {
+	scopedMetrics := make(map[string]map[string]interface{})
+	fetch := attributesFetcher(client, queryConfig, client.QueryMBeanAttributes)
+
+	for idx, query := range queryConfig {
+		attrNames := query.GetAttributeNames()
+
+		results, err := fetch(idx)
+		if err != nil {
+			if jmxErr, ok := gojmx.IsJMXError(err); ok {
+				log.Debug("Failed to get attributes: %s, for mBeanName %s: %v", attrNames, query.MBean, jmxErr)
+				continue
+			}
+			return nil, fmt.Errorf("failed to perform query: %q: for attributes: %s error: %w", query.MBean, attrNames, err)
+		}
+
+		for _, jmxAttr := range results {
+			if jmxAttr.ResponseType == gojmx.ResponseTypeErr {
+				log.Debug("Failed to retrieve attribute for query: %s status: %s", jmxAttr.Name, jmxAttr.StatusMsg)
+				continue
+			}
+
+			key, scopes, ok := splitScopedName(jmxAttr.Name, scopeRegex)
+			if !ok {
+				log.Debug("Unexpected mBeanName: %s for query: %s", jmxAttr.Name, query.MBean)
+				continue
+			}
+
+			eventKey := strings.Join(scopes, ".")
+
+			_, found := scopedMetrics[eventKey]
+			if !found {
+				scopedMetrics[eventKey] = make(map[string]interface{})
+				for i, scopeKey := range scopeKeys {
+					scopedMetrics[eventKey][scopeKey] = scopes[i]
+				}
+			}
+			scopedMetrics[eventKey][key] = jmxAttr.GetValue()
+		}
+	}
+
+	return scopedMetrics, nil
+}
+
+// splitScopedName replaces the values captured by scopeRegex with the '*' wildcard and returns the resulting key
+// together with the captured values.
+func splitScopedName(name string, scopeRegex *regexp.Regexp) (string, []string, bool) {
+	indexes := scopeRegex.FindStringSubmatchIndex(name)
+	if indexes == nil {
+		return "", nil, false
+	}
+
+	var key strings.Builder
+	var scopes []string
+
+	last := 0
+	for i := 2; i < len(indexes); i += 2 {
+		key.WriteString(name[last:indexes[i]])
+		key.WriteString("*")
+		scopes = append(scopes, name[indexes[i]:indexes[i+1]])
+		last = indexes[i+1]
+	}
+	key.WriteString(name[last:])
+
+	return key.String(), scopes, true
+}
+
 // getColumnFamilyMBeanNames will query the MBeanNames patterns to expand the wildcards ('*').
 // gojmx.QueryMBeanNames call is cheaper than fetching altogether the MBeanAttributes values.
 // This way we can apply filtering (e.g. column_families_limit) before querying the actual attribute values.
-func getColumnFamilyQueries(client *gojmx.Client, queryConfig []Query) ([]Query, error) {
+func getColumnFamilyQueries(client MBeanSource, queryConfig []Query, filter *FilteringConfig) ([]Query, error) {
 	var result []Query
-	visitedColumnFamilies := make(map[string]struct{})
+	limiter := newColumnFamilyLimiter(args.ColumnFamiliesLimit)
 
 	for _, query := range queryConfig {
 		mBeanNames, err := client.QueryMBeanNames(query.MBean)
@@ -133,39 +236,72 @@ func getColumnFamilyQueries(client *gojmx.Client, queryConfig []Query) ([]Query,
 
 			keyspace, columnFamily := matches[1], matches[2]
 
-			eventKey := keyspace + "." + columnFamily
-
-			// Discard internal keyspaces.
-			_, isFiltered := filteredKeyspace[keyspace]
-			if isFiltered {
-				continue
-			}
-
-			// Limit to maximum args.ColumnFamiliesLimit.
-			_, found := visitedColumnFamilies[eventKey]
-			if !found {
-				if len(visitedColumnFamilies) < args.ColumnFamiliesLimit {
-					visitedColumnFamilies[eventKey] = struct{}{}
-				} else {
-					log.Warn("Skipping column family %s due to limit reached. Current limit set to %d",
-						columnFamily, args.ColumnFamiliesLimit)
+			attributes := query.Attributes
+			if filter != nil {
+				scope := filterScope{Sample: columnFamilySample, MBean: query.MBean, Keyspace: keyspace, Table: columnFamily}
+				attributes = filterAttributes(attributes, *filter, scope)
+				if len(attributes) == 0 {
 					continue
 				}
 			}
 
-			query.MBean = mBeanName
-			result = append(result, query)
+			switch limiter.exclusion(keyspace, columnFamily) {
+			case reasonSystemKeyspace:
+				continue
+			case reasonLimitReached:
+				log.Warn("Skipping column family %s due to limit reached. Current limit set to %d",
+					columnFamily, args.ColumnFamiliesLimit)
+				continue
+			}
+
+			result = append(result, Query{MBean: mBeanName, Attributes: attributes})
 		}
 	}
 	return result, nil
 }
 
+// columnFamilyLimiter keeps track of the column families selected for collection.
+type columnFamilyLimiter struct {
+	limit   int
+	visited map[string]struct{}
+}
+
+func newColumnFamilyLimiter(limit int) *columnFamilyLimiter {
+	return &columnFamilyLimiter{
+		limit:   limit,
+		visited: make(map[string]struct{}),
+	}
+}
+
+// exclusion returns the reason why the column family is not collected, or an empty string when it is.
+// Internal keyspaces are discarded, and the column families are limited to the first 'limit' ones seen.
+func (l *columnFamilyLimiter) exclusion(keyspace, columnFamily string) string {
+	if _, isFiltered := filteredKeyspace[keyspace]; isFiltered {
+		return reasonSystemKeyspace
+	}
+
+	eventKey := keyspace + "." + columnFamily
+	if _, found := l.visited[eventKey]; found {
+		return ""
+	}
+	if len(l.visited) >= l.limit {
+		return reasonLimitReached
+	}
+	l.visited[eventKey] = struct{}{}
+	return ""
+}
+
 // populateMetrics will use the rawMetrics received from the JMXClient and store them into a nr-infra-sdk metric object.
 func populateMetrics(s *metric.Set, metrics map[string]interface{}, queryConfig []Query) {
 	var notFoundMetrics []string
 
 	for _, query := range queryConfig {
 		for _, attr := range query.Attributes {
+			// The partition size buckets are set by populatePartitionSizes.
+			if attr.Unit == unitPartitionSizeBuckets {
+				continue
+			}
+
 			rawSource := fmt.Sprintf("%s,attr=%s", query.MBean, attr.MBeanAttribute)
 			rawSource = columnFamilyRegex.ReplaceAllString(rawSource, "")
 
@@ -175,10 +311,12 @@ func populateMetrics(s *metric.Set, metrics map[string]interface{}, queryConfig
 			var ok bool
 
 			rawMetric, ok = metrics[rawSource]
+			if ok && attr.MBeanAttribute == recentValuesAttribute {
+				rawMetric, ok = recentValuesPercentile(attr.Alias, rawMetric)
+			}
 
-			if rawMetric != nil && percentileRegex.MatchString(rawSource) {
-				// Convert percentiles from microseconds to milliseconds
-				rawMetric = rawMetric.(float64) / 1000.0
+			if rawMetric != nil {
+				rawMetric = attr.convert(rawMetric)
 			}
 
 			if !ok {
@@ -217,3 +355,28 @@ func populateAttributes(s *metric.Set, metrics map[string]interface{}, sampleAtt
 		}
 	}
 }
+
+// sortedKeys returns the keys of the samples in order, so they are always reported in the same order.
+func sortedKeys(samples map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(samples))
+	for key := range samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// toFloat converts the numeric JMX values to float64, other values are converted to 0.
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nrjmx/gojmx"
+)
+
+const (
+	nodeStatusSample = "CassandraNodeStatusSample"
+
+	nodeStatusOK       = "OK"
+	nodeStatusWarning  = "WARNING"
+	nodeStatusCritical = "CRITICAL"
+
+	storageServiceMBean = "org.apache.cassandra.db:type=StorageService"
+)
+
+// nodeStatusAttributes are the StorageService attributes read to check the state of the node.
+var nodeStatusAttributes = []string{
+	"OperationMode",
+	"GossipRunning",
+	"NativeTransportRunning",
+	"IncrementalBackupsEnabled",
+}
+
+// nodeStatus is the outcome of the checks of the node. The fields are nil when they could not be checked: the JMX
+// ones with the cql transport, or when JMX is not reachable.
+type nodeStatus struct {
+	JMXReachable              *bool
+	CQLReachable              bool
+	OperationMode             string
+	GossipRunning             *bool
+	NativeTransportRunning    *bool
+	IncrementalBackupsEnabled *bool
+}
+
+// getNodeStatus checks that JMX answers the StorageService attributes, when a client is given, and that the native
+// transport accepts a CQL handshake.
+func getNodeStatus(jmxClient MBeanSource, config cqlConfig) nodeStatus {
+	var status nodeStatus
+
+	if jmxClient != nil {
+		reachable := false
+		status.JMXReachable = &reachable
+
+		attrs, err := jmxClient.GetMBeanAttributes(storageServiceMBean, nodeStatusAttributes...)
+		if err != nil {
+			log.Debug("Failed to check JMX status: %v", err)
+		} else {
+			reachable = true
+			for _, attr := range attrs {
+				if attr.ResponseType == gojmx.ResponseTypeErr {
+					log.Debug("Failed to retrieve attribute for node status: %s status: %s", attr.Name, attr.StatusMsg)
+					continue
+				}
+				status.setAttribute(strings.TrimPrefix(attr.Name, storageServiceMBean+",attr="), attr.GetValue())
+			}
+		}
+	}
+
+	if err := cqlHandshake(config); err != nil {
+		log.Debug("Failed to check native transport status: %v", err)
+	} else {
+		status.CQLReachable = true
+	}
+
+	return status
+}
+
+func (s *nodeStatus) setAttribute(name string, value interface{}) {
+	if name == "OperationMode" {
+		s.OperationMode, _ = value.(string)
+		return
+	}
+
+	enabled, ok := value.(bool)
+	if !ok {
+		return
+	}
+	switch name {
+	case "GossipRunning":
+		s.GossipRunning = &enabled
+	case "NativeTransportRunning":
+		s.NativeTransportRunning = &enabled
+	case "IncrementalBackupsEnabled":
+		s.IncrementalBackupsEnabled = &enabled
+	}
+}
+
+// evaluate returns the status for alerting and the reasons of a status other than OK:
+// CRITICAL when the node can't be reached, has left the ring or drained, or gossip is stopped,
+// WARNING when the node is not in NORMAL mode, or either JMX or the native transport is not available.
+func (s nodeStatus) evaluate() (string, string) {
+	var critical, warning []string
+
+	jmxReachable := s.JMXReachable != nil && *s.JMXReachable
+	switch {
+	case !jmxReachable && !s.CQLReachable:
+		critical = append(critical, "node is not reachable")
+	case !s.CQLReachable:
+		warning = append(warning, "native transport does not accept CQL connections")
+	case s.JMXReachable != nil && !jmxReachable:
+		warning = append(warning, "JMX is not reachable")
+	}
+
+	switch s.OperationMode {
+	case "", "NORMAL":
+	case "DRAINED", "DECOMMISSIONED":
+		critical = append(critical, "operation mode is "+s.OperationMode)
+	default:
+		warning = append(warning, "operation mode is "+s.OperationMode)
+	}
+
+	if s.GossipRunning != nil && !*s.GossipRunning {
+		critical = append(critical, "gossip is not running")
+	}
+	if s.NativeTransportRunning != nil && !*s.NativeTransportRunning {
+		warning = append(warning, "native transport is not running")
+	}
+
+	if len(critical) > 0 {
+		return nodeStatusCritical, strings.Join(append(critical, warning...), "; ")
+	}
+	if len(warning) > 0 {
+		return nodeStatusWarning, strings.Join(warning, "; ")
+	}
+	return nodeStatusOK, ""
+}
+
+// collectNodeStatus checks the node and reports the CassandraNodeStatusSample.
+func collectNodeStatus(e *integration.Entity, jmxClient MBeanSource) {
+	status := getNodeStatus(jmxClient, getCQLConfig())
+	populateNodeStatus(metricSet(e, nodeStatusSample, args.Hostname, args.Port, args.RemoteMonitoring), status)
+}
+
+// collectUnreachableNodeStatus reports the CassandraNodeStatusSample of a node JMX could not connect to.
+func collectUnreachableNodeStatus(e *integration.Entity) {
+	status := getNodeStatus(nil, getCQLConfig())
+	reachable := false
+	status.JMXReachable = &reachable
+	populateNodeStatus(metricSet(e, nodeStatusSample, args.Hostname, args.Port, args.RemoteMonitoring), status)
+}
+
+func populateNodeStatus(ms *metric.Set, status nodeStatus) {
+	gauges := []struct {
+		alias string
+		value *bool
+	}{
+		{"node.jmxReachable", status.JMXReachable},
+		{"node.cqlReachable", &status.CQLReachable},
+		{"node.gossipRunning", status.GossipRunning},
+		{"node.nativeTransportRunning", status.NativeTransportRunning},
+		{"node.incrementalBackupsEnabled", status.IncrementalBackupsEnabled},
+	}
+	for _, gauge := range gauges {
+		if gauge.value == nil {
+			continue
+		}
+		value := 0
+		if *gauge.value {
+			value = 1
+		}
+		setNodeStatusMetric(ms, gauge.alias, value, metric.GAUGE)
+	}
+
+	if status.OperationMode != "" {
+		setNodeStatusMetric(ms, "node.operationMode", status.OperationMode, metric.ATTRIBUTE)
+	}
+
+	value, reason := status.evaluate()
+	setNodeStatusMetric(ms, "status", value, metric.ATTRIBUTE)
+	if reason != "" {
+		setNodeStatusMetric(ms, "status.reason", reason, metric.ATTRIBUTE)
+	}
+}
+
+func setNodeStatusMetric(ms *metric.Set, alias string, value interface{}, metricType metric.SourceType) {
+	if err := ms.SetMetric(alias, value, metricType); err != nil {
+		log.Debug("Failed to set node status metric: %s: %v", alias, err)
+	}
+}
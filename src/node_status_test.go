@@ -0,0 +1,218 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeStatus_Evaluate(t *testing.T) {
+	yes, no := true, false
+
+	testCases := []struct {
+		name           string
+		status         nodeStatus
+		expectedStatus string
+		expectedReason string
+	}{
+		{
+			name:           "healthy",
+			status:         nodeStatus{JMXReachable: &yes, CQLReachable: true, OperationMode: "NORMAL", GossipRunning: &yes, NativeTransportRunning: &yes},
+			expectedStatus: nodeStatusOK,
+		},
+		{
+			name:           "cql transport",
+			status:         nodeStatus{CQLReachable: true},
+			expectedStatus: nodeStatusOK,
+		},
+		{
+			name:           "unreachable",
+			status:         nodeStatus{JMXReachable: &no},
+			expectedStatus: nodeStatusCritical,
+			expectedReason: "node is not reachable",
+		},
+		{
+			name:           "jmx unreachable",
+			status:         nodeStatus{JMXReachable: &no, CQLReachable: true},
+			expectedStatus: nodeStatusWarning,
+			expectedReason: "JMX is not reachable",
+		},
+		{
+			name:           "joining",
+			status:         nodeStatus{JMXReachable: &yes, OperationMode: "JOINING", GossipRunning: &yes, NativeTransportRunning: &no},
+			expectedStatus: nodeStatusWarning,
+			expectedReason: "native transport does not accept CQL connections; operation mode is JOINING; native transport is not running",
+		},
+		{
+			name:           "drained",
+			status:         nodeStatus{JMXReachable: &yes, OperationMode: "DRAINED", GossipRunning: &no, NativeTransportRunning: &no},
+			expectedStatus: nodeStatusCritical,
+			expectedReason: "operation mode is DRAINED; gossip is not running; native transport does not accept CQL connections; native transport is not running",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, reason := tc.status.evaluate()
+			assert.Equal(t, tc.expectedStatus, status)
+			assert.Equal(t, tc.expectedReason, reason)
+		})
+	}
+}
+
+func TestCollectMetrics_NodeStatus(t *testing.T) {
+	i := setupCollection(t)
+	server := newFakeCQLServer(t, "", "", nil)
+	args.CQLHostname, args.CQLPort = splitHostPort(t, server.listener.Addr().String())
+	args.Timeout = 1000
+	args.NodeStatus = true
+
+	require.NoError(t, collectMetrics(i, newFakeJMXClient(t, "cassandra.yml"), NewDefinitions()))
+
+	samples := samplesByEventType(i)
+	require.Len(t, samples[nodeStatusSample], 1)
+	assert.Equal(t, map[string]interface{}{
+		"event_type":                     nodeStatusSample,
+		"node.jmxReachable":              1.0,
+		"node.cqlReachable":              1.0,
+		"node.gossipRunning":             1.0,
+		"node.nativeTransportRunning":    1.0,
+		"node.incrementalBackupsEnabled": 0.0,
+		"node.operationMode":             "NORMAL",
+		"port":                           "7199",
+		"status":                         nodeStatusOK,
+	}, samples[nodeStatusSample][0])
+}
+
+func TestCollectMetrics_NodeStatusUnreachable(t *testing.T) {
+	i := setupCollection(t)
+	server := newFakeCQLServer(t, "", "", nil)
+	args.CQLHostname, args.CQLPort = splitHostPort(t, server.listener.Addr().String())
+	args.Timeout = 1000
+	args.NodeStatus = true
+	require.NoError(t, server.listener.Close())
+
+	client := newFakeJMXClient(t, "cassandra.yml")
+	client.inject("org.apache.cassandra.db:type=StorageService", faultTimeout)
+
+	require.Error(t, collectMetrics(i, client, NewDefinitions()))
+
+	samples := samplesByEventType(i)
+	require.Len(t, samples[nodeStatusSample], 1)
+	sample := samples[nodeStatusSample][0]
+	assert.Equal(t, 0.0, sample["node.jmxReachable"])
+	assert.Equal(t, 0.0, sample["node.cqlReachable"])
+	assert.NotContains(t, sample, "node.operationMode")
+	assert.Equal(t, nodeStatusCritical, sample["status"])
+	assert.Equal(t, "node is not reachable", sample["status.reason"])
+}
+
+func TestRunSystemViewsCollection_NodeStatus(t *testing.T) {
+	i := setupCollection(t)
+	setupSystemViewsCollection(t)
+	args.NodeStatus = true
+
+	require.NoError(t, runSystemViewsCollection(i))
+
+	samples := samplesByEventType(i)
+	require.Len(t, samples[nodeStatusSample], 1)
+	sample := samples[nodeStatusSample][0]
+	assert.Equal(t, 1.0, sample["node.cqlReachable"])
+	assert.NotContains(t, sample, "node.jmxReachable")
+	assert.Equal(t, nodeStatusOK, sample["status"])
+}
+
+// publishedSamples returns the samples published by the integration, grouped by event type.
+func publishedSamples(t *testing.T, output *bytes.Buffer) map[string][]map[string]interface{} {
+	t.Helper()
+
+	var payload struct {
+		Data []struct {
+			Metrics []map[string]interface{} `json:"metrics"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &payload))
+
+	samples := make(map[string][]map[string]interface{})
+	for _, entity := range payload.Data {
+		for _, sample := range entity.Metrics {
+			eventType := sample["event_type"].(string)
+			samples[eventType] = append(samples[eventType], sample)
+		}
+	}
+	return samples
+}
+
+func setupNodeStatusPublish(t *testing.T) (*integration.Integration, *bytes.Buffer) {
+	t.Helper()
+
+	setupCollection(t)
+	server := newFakeCQLServer(t, "", "", nil)
+	args.CQLHostname, args.CQLPort = splitHostPort(t, server.listener.Addr().String())
+	args.Timeout = 1000
+	args.NodeStatus = true
+
+	var output bytes.Buffer
+	i, err := integration.New(integrationName, integrationVersion, integration.InMemoryStore(), integration.Writer(&output))
+	require.NoError(t, err)
+	return i, &output
+}
+
+func TestPublishNodeStatus_ConnectionFailed(t *testing.T) {
+	i, output := setupNodeStatusPublish(t)
+
+	publishNodeStatus(i, nil)
+
+	samples := publishedSamples(t, output)
+	require.Len(t, samples[nodeStatusSample], 1)
+	sample := samples[nodeStatusSample][0]
+	assert.Equal(t, 0.0, sample["node.jmxReachable"])
+	assert.Equal(t, 1.0, sample["node.cqlReachable"])
+	assert.Equal(t, nodeStatusWarning, sample["status"])
+	assert.Equal(t, "JMX is not reachable", sample["status.reason"])
+}
+
+func TestPublishNodeStatus_CollectionFailed(t *testing.T) {
+	i, output := setupNodeStatusPublish(t)
+	client := newFakeJMXClient(t, "cassandra.yml")
+	client.inject("org.apache.cassandra.metrics:type=Storage,*", faultClientError)
+
+	require.Error(t, collectMetrics(i, client, NewDefinitions()))
+	publishNodeStatus(i, client)
+
+	samples := publishedSamples(t, output)
+	require.Len(t, samples, 1, "only the node status is published")
+	require.Len(t, samples[nodeStatusSample], 1)
+	assert.Equal(t, 1.0, samples[nodeStatusSample][0]["node.jmxReachable"])
+	assert.Equal(t, nodeStatusOK, samples[nodeStatusSample][0]["status"])
+}
+
+func TestPublishNodeStatus_SystemViewsFailed(t *testing.T) {
+	i, output := setupNodeStatusPublish(t)
+	args.Transport = transportCQL
+
+	publishNodeStatus(i, nil)
+
+	samples := publishedSamples(t, output)
+	require.Len(t, samples[nodeStatusSample], 1)
+	assert.NotContains(t, samples[nodeStatusSample][0], "node.jmxReachable")
+	assert.Equal(t, 1.0, samples[nodeStatusSample][0]["node.cqlReachable"])
+}
+
+func TestPublishNodeStatus_Disabled(t *testing.T) {
+	i, output := setupNodeStatusPublish(t)
+	args.NodeStatus = false
+
+	publishNodeStatus(i, nil)
+
+	assert.Empty(t, output.String())
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+// populatePartitionSizes sets the metrics computed from the buckets of the EstimatedPartitionSizeHistogram gauge.
+// The buckets are a long[], which nrjmx cannot read, so they are only available with the jolokia transport.
+func populatePartitionSizes(s *metric.Set, metrics map[string]interface{}, queryConfig []Query, thresholdMiB int) {
+	for _, query := range queryConfig {
+		for _, attr := range query.Attributes {
+			if attr.Unit != unitPartitionSizeBuckets {
+				continue
+			}
+
+			rawSource := columnFamilyRegex.ReplaceAllString(fmt.Sprintf("%s,attr=%s", query.MBean, attr.MBeanAttribute), "")
+			value := partitionSizeStat(attr.Alias, metrics[rawSource], thresholdMiB)
+			if value == nil {
+				continue
+			}
+			if err := s.SetMetric(attr.Alias, value, attr.MetricType); err != nil {
+				log.Debug("Failed to set metric value: %v", err)
+			}
+		}
+	}
+}
+
+// partitionSizeStat returns the statistic named by the alias from the buckets of the EstimatedPartitionSizeHistogram
+// gauge: the percentile of the partition sizes in bytes for the percentile aliases, or else the estimated number of
+// partitions larger than thresholdMiB. It returns nil when the value is not a list of buckets, or the percentile
+// can't be computed as the histogram overflowed.
+func partitionSizeStat(alias string, value interface{}, thresholdMiB int) interface{} {
+	buckets, ok := parseHistogramBuckets(value)
+	if !ok {
+		return nil
+	}
+
+	if percentile, ok := aliasPercentile(alias); ok {
+		size, ok := histogramPercentile(buckets, percentile)
+		if !ok {
+			return nil
+		}
+		return size
+	}
+	return histogramCountAbove(buckets, int64(thresholdMiB)*1024*1024)
+}
+
+// parseHistogramBuckets reads the bucket counts of a histogram gauge, reported by the MBean sources as a list of
+// numbers separated by commas or spaces, optionally in brackets.
+func parseHistogramBuckets(value interface{}) ([]int64, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+
+	fields := strings.FieldsFunc(strings.Trim(s, "[]"), func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) < 2 {
+		return nil, false
+	}
+
+	buckets := make([]int64, len(fields))
+	for i, field := range fields {
+		count, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, false
+		}
+		buckets[i] = int64(count)
+	}
+	return buckets, true
+}
+
+// histogramOffsets returns the upper bounds of the buckets of a Cassandra EstimatedHistogram, each one 20% larger
+// than the previous one. The last bucket, past the offsets, counts the overflowed values.
+func histogramOffsets(size int) []int64 {
+	offsets := make([]int64, size)
+	last := int64(1)
+	for i := range offsets {
+		offsets[i] = last
+		next := int64(math.Round(float64(last) * 1.2))
+		if next == last {
+			next++
+		}
+		last = next
+	}
+	return offsets
+}
+
+// histogramPercentile returns the upper bound of the bucket holding the percentile, like nodetool tablehistograms.
+func histogramPercentile(buckets []int64, percentile float64) (int64, bool) {
+	overflow := len(buckets) - 1
+	if buckets[overflow] > 0 {
+		return 0, false
+	}
+
+	var total int64
+	for _, count := range buckets {
+		total += count
+	}
+	target := int64(math.Ceil(float64(total) * percentile))
+	if target == 0 {
+		return 0, true
+	}
+
+	offsets := histogramOffsets(overflow)
+	var elements int64
+	for i := 0; i < overflow; i++ {
+		elements += buckets[i]
+		if elements >= target {
+			return offsets[i], true
+		}
+	}
+	return 0, true
+}
+
+// histogramCountAbove returns the count of the buckets whose values are all larger than the threshold, including
+// the overflowed ones.
+func histogramCountAbove(buckets []int64, threshold int64) int64 {
+	offsets := histogramOffsets(len(buckets) - 1)
+
+	var count int64
+	for i, bucketCount := range buckets {
+		// The bucket holds the values larger than the offset of the previous one.
+		if i > 0 && offsets[i-1] >= threshold {
+			count += bucketCount
+		}
+	}
+	return count
+}
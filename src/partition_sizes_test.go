@@ -0,0 +1,122 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// partitionSizeBuckets returns the 151 buckets of an EstimatedPartitionSizeHistogram: 98 partitions of up to 1109
+// bytes, one of up to 1331 bytes and one of up to 129557750 bytes, over 100MiB.
+func partitionSizeBuckets() []interface{} {
+	buckets := make([]interface{}, 151)
+	for i := range buckets {
+		buckets[i] = 0.0
+	}
+	buckets[34], buckets[35], buckets[98] = 98.0, 1.0, 1.0
+	return buckets
+}
+
+// joinBuckets returns the buckets as reported by the jolokia transport.
+func joinBuckets(buckets []interface{}) string {
+	values := make([]string, len(buckets))
+	for i, bucket := range buckets {
+		values[i] = fmt.Sprint(bucket)
+	}
+	return strings.Join(values, ",")
+}
+
+func TestHistogramOffsets(t *testing.T) {
+	offsets := histogramOffsets(150)
+
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 10, 12}, offsets[:10])
+	assert.Equal(t, int64(1109), offsets[34])
+	assert.Equal(t, int64(129557750), offsets[98])
+}
+
+func TestPartitionSizeStat(t *testing.T) {
+	buckets := joinBuckets(partitionSizeBuckets())
+
+	assert.Equal(t, int64(1109), partitionSizeStat("db.partitionSize50thPercentileBytes", buckets, 100))
+	assert.Equal(t, int64(1331), partitionSizeStat("db.partitionSize99thPercentileBytes", buckets, 100))
+	assert.Equal(t, int64(129557750), partitionSizeStat("db.partitionSize999thPercentileBytes", buckets, 100))
+	assert.Equal(t, int64(1), partitionSizeStat("db.partitionsOverWarningThreshold", buckets, 100))
+	assert.Equal(t, int64(0), partitionSizeStat("db.partitionSize50thPercentileBytes", "[0 0 0]", 100))
+
+	assert.Equal(t, int64(1), partitionSizeStat("db.partitionsOverWarningThreshold", buckets, 1))
+	assert.Equal(t, int64(0), partitionSizeStat("db.partitionsOverWarningThreshold", buckets, 200))
+
+	overflowed := strings.TrimSuffix(buckets, ",0") + ",1"
+	assert.Nil(t, partitionSizeStat("db.partitionSize50thPercentileBytes", overflowed, 100))
+	assert.Equal(t, int64(2), partitionSizeStat("db.partitionsOverWarningThreshold", overflowed, 100))
+
+	assert.Nil(t, partitionSizeStat("db.partitionSize50thPercentileBytes", "", 100))
+	assert.Nil(t, partitionSizeStat("db.partitionSize50thPercentileBytes", int64(10), 100))
+}
+
+// partitionSizesClient serves the per table MBeans of the users.profiles table.
+func partitionSizesClient(t *testing.T) *fakeJMXClient {
+	t.Helper()
+
+	client := newFakeJMXClient(t, "cassandra.yml")
+	for name, attributes := range map[string]map[string]interface{}{
+		"EstimatedPartitionSizeHistogram": {"Value": partitionSizeBuckets()},
+		"MaxPartitionSize":                {"Value": 129557750},
+		"LiveScannedHistogram":            {"Count": 250, "99thPercentile": 100.0},
+		"TombstoneWarnings":               {"Count": 3},
+		"TombstoneFailures":               {"Count": 1},
+	} {
+		client.mBeans["org.apache.cassandra.metrics:type=Table,keyspace=users,scope=profiles,name="+name] = attributes
+	}
+	return client
+}
+
+func TestCollectMetrics_PartitionSizes(t *testing.T) {
+	i := setupCollection(t)
+	args.LargePartitionThreshold = 100
+	stub := newJolokiaStub(t, partitionSizesClient(t))
+
+	require.NoError(t, collectMetrics(i, stub.client(t), NewDefinitions()))
+
+	profiles := profilesSample(t, samplesByEventType(i)[columnFamilySample])
+	assert.Equal(t, 1109.0, profiles["db.partitionSize50thPercentileBytes"])
+	assert.Equal(t, 1331.0, profiles["db.partitionSize99thPercentileBytes"])
+	assert.Equal(t, 1.0, profiles["db.partitionsOverWarningThreshold"])
+	assert.Equal(t, 129557750.0, profiles["db.partitionSizeMaxBytes"])
+	assert.Equal(t, 250.0, profiles["db.liveScannedHistogramCount"])
+	assert.Equal(t, 100.0, profiles["db.liveScannedHistogram99thPercentile"])
+	assert.Equal(t, 3.0, profiles["db.tombstoneWarnings"])
+	assert.Equal(t, 1.0, profiles["db.tombstoneFailures"])
+}
+
+func TestCollectMetrics_PartitionSizesJMX(t *testing.T) {
+	i := setupCollection(t)
+	args.LargePartitionThreshold = 100
+
+	require.NoError(t, collectMetrics(i, partitionSizesClient(t), NewDefinitions()))
+
+	// nrjmx cannot read the buckets, the rest of the metrics are reported.
+	profiles := profilesSample(t, samplesByEventType(i)[columnFamilySample])
+	assert.NotContains(t, profiles, "db.partitionSize50thPercentileBytes")
+	assert.NotContains(t, profiles, "db.partitionsOverWarningThreshold")
+	assert.Equal(t, 129557750.0, profiles["db.partitionSizeMaxBytes"])
+	assert.Equal(t, 3.0, profiles["db.tombstoneWarnings"])
+}
+
+func TestQuery_GetAttributeNames(t *testing.T) {
+	query := Query{Attributes: []Attribute{
+		{MBeanAttribute: "Value", Alias: "db.partitionSize50thPercentileBytes"},
+		{MBeanAttribute: "Value", Alias: "db.partitionsOverWarningThreshold"},
+		{MBeanAttribute: "Count", Alias: "db.count"},
+	}}
+
+	assert.Equal(t, []string{"Value", "Count"}, query.GetAttributeNames())
+}
@@ -7,22 +7,56 @@ import "github.com/newrelic/infra-integrations-sdk/v3/data/metric"
 type Query struct {
 	MBean      string      `yaml:"mbean"`
 	Attributes []Attribute `yaml:"attributes"`
+	// Every is the interval multiplier in long-running mode: the query is collected once every Every intervals.
+	// Zero or one means every interval.
+	Every int `yaml:"every,omitempty"`
 }
 
 // GetAttributeNames will iterate over the attributes to retrieve a slice with only the attribute names.
-// This is handy when performing the JMX query.
+// This is handy when performing the JMX query. The attributes read by several metrics, like the buckets of the
+// EstimatedPartitionSizeHistogram, are queried once.
 func (q *Query) GetAttributeNames() []string {
-	attrs := make([]string, len(q.Attributes))
+	attrs := make([]string, 0, len(q.Attributes))
+	seen := make(map[string]struct{}, len(q.Attributes))
 
 	for i := range q.Attributes {
-		attrs[i] = q.Attributes[i].MBeanAttribute
+		name := q.Attributes[i].MBeanAttribute
+		if _, found := seen[name]; found {
+			continue
+		}
+		seen[name] = struct{}{}
+		attrs = append(attrs, name)
 	}
 	return attrs
 }
 
+// Units of the JMX attributes. Only microseconds are converted, to milliseconds, and mebibytes, to bytes,
+// the rest are reported as is. The partition size buckets are converted to the statistic named by the alias by
+// populatePartitionSizes.
+const (
+	unitMicroseconds         = "microseconds"
+	unitCount                = "count"
+	unitBytes                = "bytes"
+	unitMebibytes            = "mebibytes"
+	unitPartitionSizeBuckets = "partition_size_buckets"
+)
+
 // Attribute maps the JMX Attribute to the NR metric. Alias defines the name of the metric in NR.
+// Unit is the unit of the JMX value, which defines the conversion applied before reporting it.
 type Attribute struct {
 	MBeanAttribute string            `yaml:"mbean_attribute"`
 	Alias          string            `yaml:"alias"`
 	MetricType     metric.SourceType `yaml:"metric_type"`
+	Unit           string            `yaml:"unit,omitempty"`
+}
+
+// convert returns the value in the unit reported to NR.
+func (a Attribute) convert(value interface{}) interface{} {
+	switch a.Unit {
+	case unitMicroseconds:
+		return toFloat(value) / 1000.0
+	case unitMebibytes:
+		return toFloat(value) * 1024 * 1024
+	}
+	return value
 }
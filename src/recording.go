@@ -0,0 +1,404 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+	"github.com/newrelic/nrjmx/gojmx"
+)
+
+const (
+	methodQueryMBeanNames      = "QueryMBeanNames"
+	methodQueryMBeanAttributes = "QueryMBeanAttributes"
+	methodGetMBeanAttributes   = "GetMBeanAttributes"
+
+	errTypeJMX        = "jmx"
+	errTypeConnection = "connection"
+	errTypeClient     = "client"
+	errTypeOther      = "other"
+
+	scrubbedValue = "(omitted value)"
+)
+
+var (
+	// secretAttributeRegex matches the attribute names whose values are scrubbed from the recordings.
+	secretAttributeRegex = regexp.MustCompile("(?i)password|secret|credential|token")
+
+	// ipAddressRegex matches the IPv4 and IPv6 addresses scrubbed from the recordings.
+	ipAddressRegex = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|\b(?:[0-9a-fA-F]{1,4}:){2,7}[0-9a-fA-F]{1,4}\b`)
+)
+
+// recording is the file format used to store the MBeanSource calls.
+type recording struct {
+	Calls []recordedCall `json:"calls"`
+}
+
+// recordedCall keeps the request and the response of a single MBeanSource call.
+type recordedCall struct {
+	Method     string                     `json:"method"`
+	MBean      string                     `json:"mbean"`
+	Attributes []string                   `json:"attributes,omitempty"`
+	Names      []string                   `json:"names,omitempty"`
+	Responses  []*gojmx.AttributeResponse `json:"responses,omitempty"`
+	Error      *recordedError             `json:"error,omitempty"`
+}
+
+// recordedError keeps the kind of error so it is handled in the same way when replayed.
+type recordedError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func (c recordedCall) key() string {
+	return c.Method + "|" + c.MBean + "|" + strings.Join(c.Attributes, ",")
+}
+
+func newRecordedError(err error) *recordedError {
+	if err == nil {
+		return nil
+	}
+
+	errType := errTypeOther
+	if _, ok := gojmx.IsJMXError(err); ok {
+		errType = errTypeJMX
+	} else if _, ok := gojmx.IsJMXConnectionError(err); ok {
+		errType = errTypeConnection
+	} else if _, ok := gojmx.IsJMXClientError(err); ok {
+		errType = errTypeClient
+	}
+
+	return &recordedError{Type: errType, Message: err.Error()}
+}
+
+func (e *recordedError) toError() error {
+	if e == nil {
+		return nil
+	}
+
+	switch e.Type {
+	case errTypeJMX:
+		return &gojmx.JMXError{Message: e.Message}
+	case errTypeConnection:
+		return &gojmx.JMXConnectionError{Message: e.Message}
+	case errTypeClient:
+		return &gojmx.JMXClientError{Message: e.Message}
+	default:
+		return fmt.Errorf("%s", e.Message)
+	}
+}
+
+// recordingSource wraps an MBeanSource storing every call, the recording is written to the file when flushed and
+// when closed. In long-running mode only the first cycles are recorded, so the recording doesn't grow forever.
+type recordingSource struct {
+	MBeanSource
+	path     string
+	scrubber *scrubber
+	calls    []recordedCall
+	// cycles is the number of collections recorded, and recorded the ones completed so far.
+	cycles   int
+	recorded int
+}
+
+var _ MBeanSource = (*recordingSource)(nil)
+
+// newRecordingSource records the calls performed to the source during the given number of collection cycles, at
+// least one. If scrub is set, secrets and hostnames are removed from the recording.
+func newRecordingSource(source MBeanSource, path string, scrub bool, cycles int) *recordingSource {
+	r := &recordingSource{
+		MBeanSource: source,
+		path:        path,
+		cycles:      max(cycles, 1),
+	}
+	if scrub {
+		r.scrubber = newScrubber(args.Hostname, args.Username, args.Password)
+	}
+	return r
+}
+
+func (r *recordingSource) QueryMBeanNames(mBeanGlobPattern string) ([]string, error) {
+	names, err := r.MBeanSource.QueryMBeanNames(mBeanGlobPattern)
+	r.record(recordedCall{
+		Method: methodQueryMBeanNames,
+		MBean:  mBeanGlobPattern,
+		Names:  names,
+		Error:  newRecordedError(err),
+	})
+	return names, err
+}
+
+func (r *recordingSource) QueryMBeanAttributes(mBeanNamePattern string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error) {
+	responses, err := r.MBeanSource.QueryMBeanAttributes(mBeanNamePattern, mBeanAttrName...)
+	r.record(recordedCall{
+		Method:     methodQueryMBeanAttributes,
+		MBean:      mBeanNamePattern,
+		Attributes: mBeanAttrName,
+		Responses:  responses,
+		Error:      newRecordedError(err),
+	})
+	return responses, err
+}
+
+func (r *recordingSource) GetMBeanAttributes(mBeanName string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error) {
+	responses, err := r.MBeanSource.GetMBeanAttributes(mBeanName, mBeanAttrName...)
+	r.record(recordedCall{
+		Method:     methodGetMBeanAttributes,
+		MBean:      mBeanName,
+		Attributes: mBeanAttrName,
+		Responses:  responses,
+		Error:      newRecordedError(err),
+	})
+	return responses, err
+}
+
+// Flush writes the calls recorded so far to the file.
+func (r *recordingSource) Flush() error {
+	if r.completed() {
+		return nil
+	}
+	return r.save()
+}
+
+// EndCycle writes the calls recorded so far to the file and, once the cycles to record are completed, stops
+// recording the calls.
+func (r *recordingSource) EndCycle() error {
+	if r.completed() {
+		return nil
+	}
+
+	err := r.save()
+	r.recorded++
+	if r.completed() {
+		log.Info("Recorded %d collections to %s, the next ones are not recorded", r.recorded, r.path)
+		r.calls = nil
+	}
+	return err
+}
+
+func (r *recordingSource) completed() bool {
+	return r.recorded >= r.cycles
+}
+
+// Close writes the recording to the file and closes the wrapped source.
+func (r *recordingSource) Close() error {
+	if err := r.Flush(); err != nil {
+		log.Error("Failed to save recording: %v", err)
+	}
+	return r.MBeanSource.Close()
+}
+
+func (r *recordingSource) record(call recordedCall) {
+	if r.completed() {
+		return
+	}
+	if r.scrubber != nil {
+		call = r.scrubber.scrubCall(call)
+	}
+	r.calls = append(r.calls, call)
+}
+
+func (r *recordingSource) save() error {
+	content, err := json.MarshalIndent(recording{Calls: r.calls}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recording: %w", err)
+	}
+
+	if err := os.WriteFile(r.path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write recording to %q: %w", r.path, err)
+	}
+
+	log.Debug("Recorded %d calls to %s", len(r.calls), r.path)
+	return nil
+}
+
+// flushRecording writes the calls recorded so far when the source is being recorded, so they are kept when the
+// process exits without closing the source, like in long-running mode or on a fatal error.
+func flushRecording(source MBeanSource) {
+	recorder, ok := source.(*recordingSource)
+	if !ok {
+		return
+	}
+	if err := recorder.Flush(); err != nil {
+		log.Error("Failed to save recording: %v", err)
+	}
+}
+
+// endRecordingCycle writes the calls of the collection cycle when the source is being recorded, and stops
+// recording after RECORD_CYCLES collections of long-running mode.
+func endRecordingCycle(source MBeanSource) {
+	recorder, ok := source.(*recordingSource)
+	if !ok {
+		return
+	}
+	if err := recorder.EndCycle(); err != nil {
+		log.Error("Failed to save recording: %v", err)
+	}
+}
+
+// replaySource is an MBeanSource serving the calls stored in a recording. Repeated calls are served in the
+// recorded order, and the last response is repeated once all of them are consumed.
+type replaySource struct {
+	calls  map[string][]recordedCall
+	served map[string]int
+}
+
+var _ MBeanSource = (*replaySource)(nil)
+
+// newReplaySource loads the recording file.
+func newReplaySource(path string) (*replaySource, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	var rec recording
+	if err := json.Unmarshal(content, &rec); err != nil {
+		return nil, fmt.Errorf("failed to decode recording %q: %w", path, err)
+	}
+
+	r := &replaySource{
+		calls:  make(map[string][]recordedCall),
+		served: make(map[string]int),
+	}
+	for _, call := range rec.Calls {
+		r.calls[call.key()] = append(r.calls[call.key()], call)
+	}
+	return r, nil
+}
+
+func (r *replaySource) QueryMBeanNames(mBeanGlobPattern string) ([]string, error) {
+	call, err := r.next(recordedCall{Method: methodQueryMBeanNames, MBean: mBeanGlobPattern})
+	if err != nil {
+		return nil, err
+	}
+	return call.Names, call.Error.toError()
+}
+
+func (r *replaySource) QueryMBeanAttributes(mBeanNamePattern string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error) {
+	call, err := r.next(recordedCall{Method: methodQueryMBeanAttributes, MBean: mBeanNamePattern, Attributes: mBeanAttrName})
+	if err != nil {
+		return nil, err
+	}
+	return call.Responses, call.Error.toError()
+}
+
+func (r *replaySource) GetMBeanAttributes(mBeanName string, mBeanAttrName ...string) ([]*gojmx.AttributeResponse, error) {
+	call, err := r.next(recordedCall{Method: methodGetMBeanAttributes, MBean: mBeanName, Attributes: mBeanAttrName})
+	if err != nil {
+		return nil, err
+	}
+	return call.Responses, call.Error.toError()
+}
+
+func (r *replaySource) IsRunning() bool {
+	return true
+}
+
+func (r *replaySource) Close() error {
+	return nil
+}
+
+// next returns the recorded call matching the request. Calls not found in the recording are reported as
+// JMX errors so they are skipped like a missing mBean.
+func (r *replaySource) next(request recordedCall) (recordedCall, error) {
+	key := request.key()
+
+	calls, ok := r.calls[key]
+	if !ok {
+		return recordedCall{}, &gojmx.JMXError{Message: fmt.Sprintf("%s call not found in recording for: %s", request.Method, request.MBean)}
+	}
+
+	idx := r.served[key]
+	if idx < len(calls)-1 {
+		r.served[key]++
+	}
+	return calls[idx], nil
+}
+
+// scrubber removes the secrets and hostnames from the values of the recorded calls. The MBean and attribute names
+// are kept as they are the keys of the calls when replayed, and a secret like 'cassandra' would break them.
+type scrubber struct {
+	secrets      []string
+	hostnames    map[string]string
+	hostnameList []string
+}
+
+func newScrubber(hostname string, secrets ...string) *scrubber {
+	s := &scrubber{
+		hostnames: make(map[string]string),
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			s.secrets = append(s.secrets, secret)
+		}
+	}
+	if hostname != "" {
+		s.placeholder(hostname)
+	}
+	return s
+}
+
+func (s *scrubber) scrubCall(call recordedCall) recordedCall {
+	responses := make([]*gojmx.AttributeResponse, len(call.Responses))
+	for i, response := range call.Responses {
+		scrubbed := *response
+		scrubbed.StatusMsg = s.scrub(scrubbed.StatusMsg)
+		if secretAttributeRegex.MatchString(attributeName(scrubbed.Name)) && scrubbed.ResponseType != gojmx.ResponseTypeErr {
+			scrubbed.ResponseType = gojmx.ResponseTypeString
+			scrubbed.StringValue = scrubbedValue
+			scrubbed.IntValue, scrubbed.DoubleValue, scrubbed.BoolValue = 0, 0, false
+		} else {
+			scrubbed.StringValue = s.scrub(scrubbed.StringValue)
+		}
+		responses[i] = &scrubbed
+	}
+	if call.Responses != nil {
+		call.Responses = responses
+	}
+
+	if call.Error != nil {
+		call.Error = &recordedError{Type: call.Error.Type, Message: s.scrub(call.Error.Message)}
+	}
+	return call
+}
+
+// scrub replaces the secrets and the hostnames found in the value.
+func (s *scrubber) scrub(value string) string {
+	for _, secret := range s.secrets {
+		value = strings.ReplaceAll(value, secret, scrubbedValue)
+	}
+
+	value = ipAddressRegex.ReplaceAllStringFunc(value, s.placeholder)
+	for _, hostname := range s.hostnameList {
+		value = strings.ReplaceAll(value, hostname, s.hostnames[hostname])
+	}
+	return value
+}
+
+// placeholder returns the replacement for the hostname, the first one seen is 'host-1' and so on.
+func (s *scrubber) placeholder(hostname string) string {
+	if p, ok := s.hostnames[hostname]; ok {
+		return p
+	}
+	p := fmt.Sprintf("host-%d", len(s.hostnames)+1)
+	s.hostnames[hostname] = p
+	s.hostnameList = append(s.hostnameList, hostname)
+	return p
+}
+
+// attributeName returns the attribute name from the nrjmx '<mBeanName>,attr=<attribute>' format.
+func attributeName(name string) string {
+	idx := strings.LastIndex(name, ",attr=")
+	if idx < 0 {
+		return name
+	}
+	return name[idx+len(",attr="):]
+}
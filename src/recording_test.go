@@ -0,0 +1,224 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/nrjmx/gojmx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestRecordAndReplay(t *testing.T) {
+	recordingPath := filepath.Join(t.TempDir(), "recording.json")
+
+	i := setupCollection(t)
+	args.ThreadPoolMetrics = true
+	fake := newFakeJMXClient(t, "cassandra.yml")
+	fake.inject("org.apache.cassandra.metrics:type=Client,*", faultJMXError)
+
+	recorder := newRecordingSource(fake, recordingPath, false, 1)
+	require.NoError(t, collectMetrics(i, recorder, NewDefinitions()))
+	require.NoError(t, recorder.Close())
+
+	replay, err := newReplaySource(recordingPath)
+	require.NoError(t, err)
+
+	replayed, err := integration.New(integrationName, integrationVersion, integration.InMemoryStore())
+	require.NoError(t, err)
+	require.NoError(t, collectMetrics(replayed, replay, NewDefinitions()))
+
+	assert.Equal(t, samplesByEventType(i), samplesByEventType(replayed))
+	assert.Nil(t, samplesByEventType(replayed)["CassandraSample"][0]["client.connectedNativeClients"])
+}
+
+func TestReplay_ErrorsKeepTheirType(t *testing.T) {
+	recordingPath := filepath.Join(t.TempDir(), "recording.json")
+
+	fake := newFakeJMXClient(t, "cassandra.yml")
+	fake.inject("org.apache.cassandra.metrics:type=Storage,*", faultTimeout)
+
+	recorder := newRecordingSource(fake, recordingPath, false, 1)
+	_, err := recorder.QueryMBeanAttributes("org.apache.cassandra.metrics:type=Storage,name=Load", "Count")
+	require.Error(t, err)
+	require.NoError(t, recorder.Close())
+
+	replay, err := newReplaySource(recordingPath)
+	require.NoError(t, err)
+
+	_, err = replay.QueryMBeanAttributes("org.apache.cassandra.metrics:type=Storage,name=Load", "Count")
+	_, isConnErr := gojmx.IsJMXConnectionError(err)
+	assert.True(t, isConnErr)
+
+	_, err = replay.QueryMBeanAttributes("org.apache.cassandra.metrics:type=Storage,name=Other", "Count")
+	_, isJMXErr := gojmx.IsJMXError(err)
+	assert.True(t, isJMXErr, "calls missing from the recording should be skipped")
+}
+
+func TestRecord_Scrub(t *testing.T) {
+	recordingPath := filepath.Join(t.TempDir(), "recording.json")
+
+	setupCollection(t)
+	args.Hostname = "cassandra-01.example.com"
+	args.Password = "s3cr3t"
+
+	fake := &fakeJMXClient{
+		running: true,
+		mBeans: map[string]map[string]interface{}{
+			"org.apache.cassandra.metrics:type=HintedHandOffManager,name=Hints_created-10.0.0.12": {"Count": 3},
+			"org.apache.cassandra.db:type=StorageService": {
+				"ClusterName":    "cluster on cassandra-01.example.com",
+				"LiveNodes":      "10.0.0.12,10.0.0.13",
+				"ClientPassword": "s3cr3t",
+				"Keystore":       "secret=s3cr3t",
+			},
+		},
+	}
+
+	recorder := newRecordingSource(fake, recordingPath, true, 1)
+	names, err := recorder.QueryMBeanNames("org.apache.cassandra.metrics:type=HintedHandOffManager,*")
+	require.NoError(t, err)
+	_, err = recorder.GetMBeanAttributes(names[0], "Count")
+	require.NoError(t, err)
+	_, err = recorder.QueryMBeanAttributes("org.apache.cassandra.db:type=StorageService")
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	content, err := os.ReadFile(recordingPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "cassandra-01.example.com")
+	assert.NotContains(t, string(content), "s3cr3t")
+
+	replay, err := newReplaySource(recordingPath)
+	require.NoError(t, err)
+
+	replayedNames, err := replay.QueryMBeanNames("org.apache.cassandra.metrics:type=HintedHandOffManager,*")
+	require.NoError(t, err)
+	assert.Equal(t, names, replayedNames, "the MBean names are not scrubbed")
+
+	responses, err := replay.GetMBeanAttributes(replayedNames[0], "Count")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), responses[0].GetValue())
+
+	responses, err = replay.QueryMBeanAttributes("org.apache.cassandra.db:type=StorageService")
+	require.NoError(t, err)
+	values := make(map[string]interface{})
+	for _, response := range responses {
+		values[attributeName(response.Name)] = response.GetValue()
+	}
+	assert.Equal(t, map[string]interface{}{
+		"ClusterName":    "cluster on host-1",
+		"LiveNodes":      "host-2,host-3",
+		"ClientPassword": "(omitted value)",
+		"Keystore":       "secret=(omitted value)",
+	}, values)
+}
+
+func TestRecord_ScrubDefaultCredentials(t *testing.T) {
+	recordingPath := filepath.Join(t.TempDir(), "recording.json")
+
+	i := setupCollection(t)
+	args.Username, args.Password = "cassandra", "cassandra"
+	args.ThreadPoolMetrics = true
+
+	recorder := newRecordingSource(newFakeJMXClient(t, "cassandra.yml"), recordingPath, true, 1)
+	require.NoError(t, collectMetrics(i, recorder, NewDefinitions()))
+	require.NoError(t, recorder.Close())
+
+	replay, err := newReplaySource(recordingPath)
+	require.NoError(t, err)
+
+	replayed, err := integration.New(integrationName, integrationVersion, integration.InMemoryStore())
+	require.NoError(t, err)
+	require.NoError(t, collectMetrics(replayed, replay, NewDefinitions()))
+
+	assert.Equal(t, samplesByEventType(i), samplesByEventType(replayed))
+}
+
+func TestFlushRecording(t *testing.T) {
+	recordingPath := filepath.Join(t.TempDir(), "recording.json")
+
+	recorder := newRecordingSource(newFakeJMXClient(t, "cassandra.yml"), recordingPath, false, 1)
+	_, err := recorder.QueryMBeanAttributes("org.apache.cassandra.metrics:type=Storage,name=Load", "Count")
+	require.NoError(t, err)
+
+	flushRecording(recorder)
+
+	replay, err := newReplaySource(recordingPath)
+	require.NoError(t, err)
+	responses, err := replay.QueryMBeanAttributes("org.apache.cassandra.metrics:type=Storage,name=Load", "Count")
+	require.NoError(t, err)
+	assert.NotEmpty(t, responses)
+}
+
+func TestRecordingSource_Cycles(t *testing.T) {
+	recordingPath := filepath.Join(t.TempDir(), "recording.json")
+	recorder := newRecordingSource(newFakeJMXClient(t, "cassandra.yml"), recordingPath, false, 2)
+
+	for range 3 {
+		_, err := recorder.QueryMBeanAttributes("org.apache.cassandra.metrics:type=Storage,name=Load", "Count")
+		require.NoError(t, err)
+		endRecordingCycle(recorder)
+	}
+	flushRecording(recorder)
+
+	content, err := os.ReadFile(recordingPath)
+	require.NoError(t, err)
+	var rec recording
+	require.NoError(t, json.Unmarshal(content, &rec))
+	assert.Len(t, rec.Calls, 2, "only the first two collections are recorded")
+	assert.Empty(t, recorder.calls, "the calls are released once recorded")
+}
+
+// TestReplay_Golden replays a recording and compares the integration output with the golden file.
+// Run with '-update' to regenerate the files from the fake JMX fixture.
+func TestReplay_Golden(t *testing.T) {
+	recordingPath := path.Join("testdata", "recording.json")
+	goldenPath := path.Join("testdata", "replay.golden.json")
+
+	recorded := setupCollection(t)
+	args.ThreadPoolMetrics = true
+	args.DroppedMessageMetrics = true
+	args.HintsMetrics = true
+	args.LargePartitionThreshold = 100
+
+	if *updateGolden {
+		recorder := newRecordingSource(newFakeJMXClient(t, "cassandra.yml"), recordingPath, true, 1)
+		require.NoError(t, collectMetrics(recorded, recorder, NewDefinitions()))
+		require.NoError(t, recorder.Close())
+	}
+
+	replay, err := newReplaySource(recordingPath)
+	require.NoError(t, err)
+
+	var output bytes.Buffer
+	i, err := integration.New(integrationName, integrationVersion, integration.InMemoryStore(), integration.Writer(&output))
+	require.NoError(t, err)
+
+	require.NoError(t, collectMetrics(i, replay, NewDefinitions()))
+	require.NoError(t, i.Publish())
+
+	var actual bytes.Buffer
+	require.NoError(t, json.Indent(&actual, output.Bytes(), "", "  "))
+
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(goldenPath, actual.Bytes(), 0644))
+	}
+
+	expected, err := os.ReadFile(goldenPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), actual.String())
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/inventory"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+const (
+	// runtimeInventoryPrefix is the prefix of the inventory items of the effective settings of the node, so they
+	// can be compared with the ones read from cassandra.yaml.
+	runtimeInventoryPrefix = "runtime."
+
+	runtimeSourceJMX         = "jmx"
+	runtimeSourceSystemViews = "system_views.settings"
+
+	systemViewsSettingsQuery = "SELECT name, value FROM system_views.settings"
+)
+
+// runtimeInventoryQueries read the settings that can be changed at runtime with nodetool. The aliases are the
+// names of the settings in cassandra.yaml. The attributes not available in the version of the node are skipped.
+var runtimeInventoryQueries = []Query{
+	{
+		MBean: "org.apache.cassandra.db:type=StorageService",
+		Attributes: []Attribute{
+			{MBeanAttribute: "CompactionThroughputMbPerSec", Alias: "compaction_throughput_mb_per_sec"},
+			{MBeanAttribute: "StreamThroughputMbPerSec", Alias: "stream_throughput_outbound_megabits_per_sec"},
+			{MBeanAttribute: "InterDCStreamThroughputMbPerSec", Alias: "inter_dc_stream_throughput_outbound_megabits_per_sec"},
+			{MBeanAttribute: "ConcurrentCompactors", Alias: "concurrent_compactors"},
+			{MBeanAttribute: "IncrementalBackupsEnabled", Alias: "incremental_backups"},
+			{MBeanAttribute: "TombstoneWarnThreshold", Alias: "tombstone_warn_threshold"},
+			{MBeanAttribute: "TombstoneFailureThreshold", Alias: "tombstone_failure_threshold"},
+			{MBeanAttribute: "BatchSizeFailureThreshold", Alias: "batch_size_fail_threshold_in_kb"},
+			{MBeanAttribute: "RpcTimeout", Alias: "request_timeout_in_ms"},
+			{MBeanAttribute: "ReadRpcTimeout", Alias: "read_request_timeout_in_ms"},
+			{MBeanAttribute: "RangeRpcTimeout", Alias: "range_request_timeout_in_ms"},
+			{MBeanAttribute: "WriteRpcTimeout", Alias: "write_request_timeout_in_ms"},
+			{MBeanAttribute: "CounterWriteRpcTimeout", Alias: "counter_write_request_timeout_in_ms"},
+			{MBeanAttribute: "TruncateRpcTimeout", Alias: "truncate_request_timeout_in_ms"},
+		},
+	},
+	{
+		MBean: "org.apache.cassandra.db:type=StorageProxy",
+		Attributes: []Attribute{
+			{MBeanAttribute: "HintedHandoffEnabled", Alias: "hinted_handoff_enabled"},
+			{MBeanAttribute: "MaxHintWindow", Alias: "max_hint_window_in_ms"},
+			{MBeanAttribute: "MaxHintsInProgress", Alias: "max_hints_in_progress"},
+		},
+	},
+}
+
+// runtimeSetting is an effective setting of the node and where it was read from.
+type runtimeSetting struct {
+	Value  interface{}
+	Source string
+}
+
+// getRuntimeSettings reads the effective settings of the node from JMX, unless jmxClient is nil with the cql
+// transport, and, when cqlClient is set, from the system_views.settings virtual table of Cassandra 4.0 and later. The virtual table values take precedence.
+// Failing to read the virtual table is only logged, as older versions don't have it.
+func getRuntimeSettings(jmxClient MBeanSource, cqlClient CQLSource) (map[string]runtimeSetting, error) {
+	settings := make(map[string]runtimeSetting)
+
+	if jmxClient != nil {
+		rawSettings, err := getMetrics(jmxClient, runtimeInventoryQueries)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read runtime settings: %w", err)
+		}
+		for _, query := range runtimeInventoryQueries {
+			for _, attr := range query.Attributes {
+				value, ok := rawSettings[fmt.Sprintf("%s,attr=%s", query.MBean, attr.MBeanAttribute)]
+				if !ok {
+					continue
+				}
+				settings[attr.Alias] = runtimeSetting{Value: value, Source: runtimeSourceJMX}
+			}
+		}
+	}
+
+	if cqlClient == nil {
+		return settings, nil
+	}
+
+	rows, err := cqlClient.Query(systemViewsSettingsQuery)
+	if err != nil {
+		log.Warn("Failed to read the runtime settings from system_views, it requires Cassandra 4.0 or later: %v", err)
+		return settings, nil
+	}
+	for _, row := range rows {
+		name, ok := row["name"].(string)
+		if !ok || row["value"] == nil {
+			continue
+		}
+		settings[name] = runtimeSetting{Value: row["value"], Source: runtimeSourceSystemViews}
+	}
+
+	return settings, nil
+}
+
+// populateRuntimeInventory sets an item for each runtime setting, prefixed by 'runtime.', with the 'value' and
+// the 'source' it was read from.
+func populateRuntimeInventory(i *inventory.Inventory, settings map[string]runtimeSetting, redact redactPatterns) {
+	for name, setting := range settings {
+		key := runtimeInventoryPrefix + name
+		setValue(i, key, "value", truncateValue(setting.Value, args.InventoryMaxValueLength), redact)
+		if err := i.SetItem(key, "source", setting.Source); err != nil {
+			log.Error("setting item: %v", err)
+		}
+	}
+}
+
+// openCQLSource connects to the native transport of the node with the integration args.
+func openCQLSource() (CQLSource, error) {
+	client, err := newCQLClient(getCQLConfig())
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// cqlHostname returns the host of the native transport, the JMX one unless set.
+func cqlHostname() string {
+	if args.CQLHostname != "" {
+		return args.CQLHostname
+	}
+	return args.Hostname
+}
+
+// getCQLConfig will use the integration args to prepare the configuration for the CQL client.
+func getCQLConfig() cqlConfig {
+	return cqlConfig{
+		Address:            net.JoinHostPort(cqlHostname(), strconv.Itoa(args.CQLPort)),
+		Username:           args.CQLUsername,
+		Password:           args.CQLPassword,
+		Timeout:            time.Duration(args.Timeout) * time.Millisecond,
+		TLS:                args.CQLTLS,
+		CACert:             args.CQLCACert,
+		InsecureSkipVerify: args.CQLTLSSkipVerify,
+	}
+}
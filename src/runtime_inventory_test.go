@@ -0,0 +1,112 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"path"
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/inventory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCQLSource returns the rows of each statement, or the same error to any query.
+type fakeCQLSource struct {
+	results map[string][]map[string]interface{}
+	err     error
+}
+
+func (f *fakeCQLSource) Query(statement string) ([]map[string]interface{}, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.results[statement], nil
+}
+
+func (f *fakeCQLSource) Close() error {
+	return nil
+}
+
+func TestGetRuntimeSettings_JMX(t *testing.T) {
+	jmxClient := newFakeJMXClient(t, "cassandra.yml")
+
+	settings, err := getRuntimeSettings(jmxClient, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]runtimeSetting{
+		"compaction_throughput_mb_per_sec":            {Value: int64(64), Source: runtimeSourceJMX},
+		"stream_throughput_outbound_megabits_per_sec": {Value: int64(200), Source: runtimeSourceJMX},
+		"concurrent_compactors":                       {Value: int64(2), Source: runtimeSourceJMX},
+		"incremental_backups":                         {Value: false, Source: runtimeSourceJMX},
+		"hinted_handoff_enabled":                      {Value: true, Source: runtimeSourceJMX},
+		"max_hint_window_in_ms":                       {Value: int64(10800000), Source: runtimeSourceJMX},
+	}, settings)
+}
+
+func TestGetRuntimeSettings_SystemViews(t *testing.T) {
+	jmxClient := newFakeJMXClient(t, "cassandra.yml")
+	cqlClient := &fakeCQLSource{results: map[string][]map[string]interface{}{
+		systemViewsSettingsQuery: {
+			{"name": "compaction_throughput_mb_per_sec", "value": "32"},
+			{"name": "cluster_name", "value": "Test Cluster"},
+			{"name": "ideal_consistency_level", "value": nil},
+		},
+	}}
+
+	settings, err := getRuntimeSettings(jmxClient, cqlClient)
+	require.NoError(t, err)
+
+	assert.Equal(t, runtimeSetting{Value: "32", Source: runtimeSourceSystemViews}, settings["compaction_throughput_mb_per_sec"])
+	assert.Equal(t, runtimeSetting{Value: "Test Cluster", Source: runtimeSourceSystemViews}, settings["cluster_name"])
+	assert.Equal(t, runtimeSetting{Value: int64(2), Source: runtimeSourceJMX}, settings["concurrent_compactors"])
+	assert.NotContains(t, settings, "ideal_consistency_level")
+}
+
+func TestGetRuntimeSettings_NoSystemViews(t *testing.T) {
+	jmxClient := newFakeJMXClient(t, "cassandra.yml")
+	cqlClient := &fakeCQLSource{err: &CQLError{Code: 0x2200, Message: "keyspace system_views does not exist"}}
+
+	settings, err := getRuntimeSettings(jmxClient, cqlClient)
+	require.NoError(t, err)
+	assert.Equal(t, runtimeSetting{Value: int64(64), Source: runtimeSourceJMX}, settings["compaction_throughput_mb_per_sec"])
+}
+
+func TestGetRuntimeSettings_ConnectionError(t *testing.T) {
+	jmxClient := newFakeJMXClient(t, "cassandra.yml")
+	jmxClient.inject("org.apache.cassandra.db:type=StorageService", faultTimeout)
+
+	_, err := getRuntimeSettings(jmxClient, nil)
+	assert.ErrorContains(t, err, "failed to read runtime settings")
+}
+
+func TestPopulateRuntimeInventory(t *testing.T) {
+	i := inventory.New()
+	populateRuntimeInventory(i, map[string]runtimeSetting{
+		"compaction_throughput_mb_per_sec": {Value: int64(64), Source: runtimeSourceJMX},
+		"ldap_bind_password":               {Value: "12345", Source: runtimeSourceSystemViews},
+	}, testRedactPatterns(t))
+
+	assert.Equal(t, inventory.Items{
+		"runtime.compaction_throughput_mb_per_sec": {"value": int64(64), "source": "jmx"},
+		"runtime.ldap_bind_password":               {"value": "(omitted value)", "source": "system_views.settings"},
+	}, i.Items())
+}
+
+func TestRunInventoryCollection_WithoutConfigFile(t *testing.T) {
+	i := setupCollection(t)
+	args.ConfigPath = path.Join(t.TempDir(), "cassandra.yaml")
+	jmxClient := newFakeJMXClient(t, "cassandra.yml")
+
+	assert.Error(t, runInventoryCollection(i, jmxClient))
+
+	args.RuntimeInventory = true
+	require.NoError(t, runInventoryCollection(i, jmxClient))
+
+	items := i.LocalEntity().Inventory.Items()
+	assert.Equal(t, inventory.Item{"value": int64(64), "source": "jmx"}, items["runtime.compaction_throughput_mb_per_sec"])
+	assert.Equal(t, inventory.Item{"value": true, "source": "jmx"}, items["runtime.hinted_handoff_enabled"])
+}
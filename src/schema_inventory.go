@@ -0,0 +1,117 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/inventory"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+const (
+	// schemaInventoryPrefix is the prefix of the inventory items of the keyspaces and tables.
+	schemaInventoryPrefix = "schema."
+
+	keyspacesSchemaQuery = "SELECT keyspace_name, durable_writes, replication FROM system_schema.keyspaces"
+	tablesSchemaQuery    = "SELECT keyspace_name, table_name, bloom_filter_fp_chance, caching, compaction, compression, default_time_to_live, gc_grace_seconds FROM system_schema.tables"
+)
+
+// tableSchemaOptions are the options of the tables reported in the inventory, the maps are flattened into a field
+// per entry, e.g. 'compaction.class'.
+var tableSchemaOptions = []string{
+	"bloom_filter_fp_chance",
+	"caching",
+	"compaction",
+	"compression",
+	"default_time_to_live",
+	"gc_grace_seconds",
+}
+
+// getSchemaInventory reads the keyspaces and tables options from the system_schema keyspace of Cassandra 3.0 and
+// later. It returns the fields of the inventory items: 'schema.<keyspace>' with the replication and durable_writes
+// options, and 'schema.<keyspace>.<table>' with the table options of the first tablesLimit tables, in name order.
+// The system keyspaces and their tables are skipped, like in the column family metrics.
+func getSchemaInventory(cqlClient CQLSource, tablesLimit int) (map[string]map[string]interface{}, error) {
+	items := make(map[string]map[string]interface{})
+
+	keyspaces, err := cqlClient.Query(keyspacesSchemaQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the keyspaces schema: %w", err)
+	}
+	for _, row := range keyspaces {
+		keyspace, ok := row["keyspace_name"].(string)
+		if _, isFiltered := filteredKeyspace[keyspace]; !ok || isFiltered {
+			continue
+		}
+
+		fields := map[string]interface{}{"durable_writes": row["durable_writes"]}
+		flattenSchemaOption(fields, "replication", row["replication"])
+		items[schemaInventoryPrefix+keyspace] = fields
+	}
+
+	tables, err := cqlClient.Query(tablesSchemaQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the tables schema: %w", err)
+	}
+	tableRows := make(map[string]map[string]interface{})
+	for _, row := range tables {
+		keyspace, ok := row["keyspace_name"].(string)
+		if _, isFiltered := filteredKeyspace[keyspace]; !ok || isFiltered {
+			continue
+		}
+		table, ok := row["table_name"].(string)
+		if !ok {
+			continue
+		}
+		tableRows[keyspace+"."+table] = row
+	}
+
+	names := make([]string, 0, len(tableRows))
+	for name := range tableRows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tablesLimit = max(tablesLimit, 0)
+	if len(names) > tablesLimit {
+		log.Warn("Skipping %d tables of the schema inventory due to limit reached. Current limit set to %d",
+			len(names)-tablesLimit, tablesLimit)
+		names = names[:tablesLimit]
+	}
+
+	for _, name := range names {
+		fields := make(map[string]interface{})
+		for _, option := range tableSchemaOptions {
+			flattenSchemaOption(fields, option, tableRows[name][option])
+		}
+		items[schemaInventoryPrefix+name] = fields
+	}
+
+	return items, nil
+}
+
+// flattenSchemaOption adds the option to the fields, with a field per entry for the map options.
+func flattenSchemaOption(fields map[string]interface{}, option string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		for key, entry := range v {
+			fields[inventoryPath(option, key)] = entry
+		}
+	default:
+		fields[option] = v
+	}
+}
+
+// populateSchemaInventory sets an inventory item for each keyspace and table.
+func populateSchemaInventory(i *inventory.Inventory, items map[string]map[string]interface{}, redact redactPatterns) {
+	for key, fields := range items {
+		for field, value := range fields {
+			setValue(i, key, field, truncateValue(value, args.InventoryMaxValueLength), redact)
+		}
+	}
+}
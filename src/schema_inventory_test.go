@@ -0,0 +1,120 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/inventory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSchema serves the system_schema rows of a cluster with an application keyspace and table.
+func fakeSchema() *fakeCQLSource {
+	return &fakeCQLSource{results: map[string][]map[string]interface{}{
+		keyspacesSchemaQuery: {
+			{
+				"keyspace_name":  "users",
+				"durable_writes": true,
+				"replication": map[string]interface{}{
+					"class": "org.apache.cassandra.locator.NetworkTopologyStrategy",
+					"dc1":   "3",
+					"dc2":   "2",
+				},
+			},
+			{
+				"keyspace_name":  "system_auth",
+				"durable_writes": true,
+				"replication": map[string]interface{}{
+					"class":              "org.apache.cassandra.locator.SimpleStrategy",
+					"replication_factor": "1",
+				},
+			},
+		},
+		tablesSchemaQuery: {
+			{
+				"keyspace_name":          "users",
+				"table_name":             "profiles",
+				"bloom_filter_fp_chance": 0.1,
+				"caching":                map[string]interface{}{"keys": "ALL", "rows_per_partition": "NONE"},
+				"compaction": map[string]interface{}{
+					"class":              "org.apache.cassandra.db.compaction.LeveledCompactionStrategy",
+					"sstable_size_in_mb": "160",
+				},
+				"compression":          map[string]interface{}{"chunk_length_in_kb": "16", "class": "org.apache.cassandra.io.compress.LZ4Compressor"},
+				"default_time_to_live": int64(0),
+				"gc_grace_seconds":     int64(864000),
+			},
+			{
+				"keyspace_name":    "system_auth",
+				"table_name":       "roles",
+				"gc_grace_seconds": int64(7776000),
+			},
+		},
+	}}
+}
+
+func TestGetSchemaInventory(t *testing.T) {
+	items, err := getSchemaInventory(fakeSchema(), 200)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]map[string]interface{}{
+		"schema.users": {
+			"durable_writes":    true,
+			"replication.class": "org.apache.cassandra.locator.NetworkTopologyStrategy",
+			"replication.dc1":   "3",
+			"replication.dc2":   "2",
+		},
+		"schema.users.profiles": {
+			"bloom_filter_fp_chance":         0.1,
+			"caching.keys":                   "ALL",
+			"caching.rows_per_partition":     "NONE",
+			"compaction.class":               "org.apache.cassandra.db.compaction.LeveledCompactionStrategy",
+			"compaction.sstable_size_in_mb":  "160",
+			"compression.chunk_length_in_kb": "16",
+			"compression.class":              "org.apache.cassandra.io.compress.LZ4Compressor",
+			"default_time_to_live":           int64(0),
+			"gc_grace_seconds":               int64(864000),
+		},
+	}, items)
+}
+
+func TestGetSchemaInventory_TablesLimit(t *testing.T) {
+	schema := fakeSchema()
+	for _, table := range []string{"sessions", "addresses"} {
+		schema.results[tablesSchemaQuery] = append(schema.results[tablesSchemaQuery],
+			map[string]interface{}{"keyspace_name": "users", "table_name": table, "gc_grace_seconds": int64(3600)})
+	}
+
+	items, err := getSchemaInventory(schema, 2)
+	require.NoError(t, err)
+
+	assert.Contains(t, items, "schema.users")
+	assert.Contains(t, items, "schema.users.addresses")
+	assert.Contains(t, items, "schema.users.profiles")
+	assert.NotContains(t, items, "schema.users.sessions", "the tables after the limit are skipped")
+
+	items, err = getSchemaInventory(schema, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"schema.users"}, sortedKeys(items))
+}
+
+func TestGetSchemaInventory_Error(t *testing.T) {
+	_, err := getSchemaInventory(&fakeCQLSource{err: &CQLError{Code: 0x2100, Message: "unauthorized"}}, 200)
+	assert.ErrorContains(t, err, "failed to read the keyspaces schema")
+}
+
+func TestPopulateSchemaInventory(t *testing.T) {
+	items, err := getSchemaInventory(fakeSchema(), 200)
+	require.NoError(t, err)
+
+	i := inventory.New()
+	populateSchemaInventory(i, items, testRedactPatterns(t))
+
+	assert.Equal(t, "org.apache.cassandra.db.compaction.LeveledCompactionStrategy", i.Items()["schema.users.profiles"]["compaction.class"])
+	assert.Equal(t, "3", i.Items()["schema.users"]["replication.dc1"])
+}
@@ -0,0 +1,551 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/newrelic/infra-integrations-sdk/v3/data/metric"
+	"github.com/newrelic/infra-integrations-sdk/v3/integration"
+	"github.com/newrelic/infra-integrations-sdk/v3/log"
+)
+
+const (
+	// transportCQL collects the metrics from the system_views virtual tables of Cassandra 4.0 and later through the
+	// native transport, for the deployments where JMX is not reachable.
+	transportCQL = "cql"
+
+	clientSample      = "CassandraClientSample"
+	sstableTaskSample = "CassandraSSTableTaskSample"
+
+	// systemViewsRowCount is the pseudo column of the queries reporting the number of rows of the table.
+	systemViewsRowCount = "rows"
+)
+
+// NewSystemViewsDefinitions returns the definitions of the metrics collected with the cql transport. The MBean of
+// the queries is the table, followed by the row name for the tables with a row per cache or pool, e.g.
+// 'system_views.caches,name=keys', and the MBeanAttribute is the column. The aliases are the ones of the
+// equivalent JMX metrics.
+func NewSystemViewsDefinitions() Definitions {
+	return Definitions{
+		Common:              systemLocalDefinitions,
+		Metrics:             systemViewsMetricDefinitions(),
+		ColumnFamilyMetrics: systemViewsColumnFamilyDefinitions,
+		ThreadPoolMetrics:   systemViewsThreadPoolDefinitions,
+	}
+}
+
+// systemLocalDefinitions are the CQL equivalent of the commonDefinitions.
+var systemLocalDefinitions = []Query{
+	{
+		MBean: "system.local",
+		Attributes: []Attribute{
+			{MBeanAttribute: "release_version", Alias: "software.version", MetricType: metric.ATTRIBUTE},
+			{MBeanAttribute: "cluster_name", Alias: "cluster.name", MetricType: metric.ATTRIBUTE},
+			{MBeanAttribute: "data_center", Alias: "cluster.datacenter", MetricType: metric.ATTRIBUTE},
+			{MBeanAttribute: "rack", Alias: "cluster.rack", MetricType: metric.ATTRIBUTE},
+		},
+	},
+}
+
+// systemViewsMetricDefinitions are the CassandraSample metrics read from the caches and clients tables.
+func systemViewsMetricDefinitions() []Query {
+	queries := []Query{
+		{
+			MBean: "system_views.clients",
+			Attributes: []Attribute{
+				{MBeanAttribute: systemViewsRowCount, Alias: "client.connectedNativeClients", MetricType: metric.GAUGE},
+			},
+		},
+	}
+
+	caches := []struct{ name, prefix string }{
+		{"keys", "db.keyCache"},
+		{"rows", "db.rowCache"},
+		{"counters", "db.counterCache"},
+		{"chunks", "db.chunkCache"},
+	}
+	for _, cache := range caches {
+		queries = append(queries, Query{
+			MBean: "system_views.caches,name=" + cache.name,
+			Attributes: []Attribute{
+				{MBeanAttribute: "capacity_bytes", Alias: cache.prefix + "CapacityBytes", MetricType: metric.GAUGE, Unit: unitBytes},
+				{MBeanAttribute: "size_bytes", Alias: cache.prefix + "SizeBytes", MetricType: metric.GAUGE, Unit: unitBytes},
+				{MBeanAttribute: "entry_count", Alias: cache.prefix + "Entries", MetricType: metric.GAUGE, Unit: unitCount},
+				{MBeanAttribute: "hit_ratio", Alias: cache.prefix + "HitRate", MetricType: metric.GAUGE},
+				{MBeanAttribute: "hit_count", Alias: cache.prefix + "HitsPerSecond", MetricType: metric.RATE},
+				{MBeanAttribute: "request_count", Alias: cache.prefix + "RequestsPerSecond", MetricType: metric.RATE},
+			},
+		})
+	}
+	return queries
+}
+
+// systemViewsColumnFamilyDefinitions are the CassandraColumnFamilySample metrics read from the tables with a row per
+// keyspace and table.
+var systemViewsColumnFamilyDefinitions = []Query{
+	{
+		MBean: "system_views.disk_usage",
+		Attributes: []Attribute{
+			{MBeanAttribute: "mebibytes", Alias: "db.liveDiskSpaceUsedBytes", MetricType: metric.GAUGE, Unit: unitMebibytes},
+		},
+	},
+	{
+		MBean: "system_views.max_partition_size",
+		Attributes: []Attribute{
+			{MBeanAttribute: "mebibytes", Alias: "db.maxRowSize", MetricType: metric.GAUGE, Unit: unitMebibytes},
+			{MBeanAttribute: "mebibytes", Alias: "db.partitionSizeMaxBytes", MetricType: metric.GAUGE, Unit: unitMebibytes},
+		},
+	},
+	{
+		MBean: "system_views.local_read_latency",
+		Attributes: []Attribute{
+			{MBeanAttribute: "count", Alias: "query.readRequestsPerSecond", MetricType: metric.RATE},
+			{MBeanAttribute: "p50th_ms", Alias: "query.readLatency50thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "p99th_ms", Alias: "query.readLatency99thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "max_ms", Alias: "query.readLatencyMaxMilliseconds", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "system_views.local_write_latency",
+		Attributes: []Attribute{
+			{MBeanAttribute: "count", Alias: "query.writeRequestsPerSecond", MetricType: metric.RATE},
+			{MBeanAttribute: "p50th_ms", Alias: "query.writeLatency50thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "p99th_ms", Alias: "query.writeLatency99thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "max_ms", Alias: "query.writeLatencyMaxMilliseconds", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "system_views.local_scan_latency",
+		Attributes: []Attribute{
+			{MBeanAttribute: "count", Alias: "query.rangeRequestsPerSecond", MetricType: metric.RATE},
+			{MBeanAttribute: "p50th_ms", Alias: "query.rangeLatency50thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "p99th_ms", Alias: "query.rangeLatency99thPercentileMilliseconds", MetricType: metric.GAUGE},
+			{MBeanAttribute: "max_ms", Alias: "query.rangeLatencyMaxMilliseconds", MetricType: metric.GAUGE},
+		},
+	},
+	{
+		MBean: "system_views.tombstones_per_read",
+		Attributes: []Attribute{
+			{MBeanAttribute: "count", Alias: "db.tombstoneScannedHistogramCount", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "median", Alias: "db.tombstoneScannedHistogram50thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "p99th", Alias: "db.tombstoneScannedHistogram99thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "max", Alias: "db.tombstoneScannedHistogramMax", MetricType: metric.GAUGE, Unit: unitCount},
+		},
+	},
+	{
+		MBean: "system_views.rows_per_read",
+		Attributes: []Attribute{
+			{MBeanAttribute: "count", Alias: "db.liveScannedHistogramCount", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "median", Alias: "db.liveScannedHistogram50thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "p99th", Alias: "db.liveScannedHistogram99thPercentile", MetricType: metric.GAUGE, Unit: unitCount},
+			{MBeanAttribute: "max", Alias: "db.liveScannedHistogramMax", MetricType: metric.GAUGE, Unit: unitCount},
+		},
+	},
+}
+
+// systemViewsThreadPoolDefinitions are the CassandraThreadPoolSample metrics read from the thread_pools table.
+var systemViewsThreadPoolDefinitions = []Query{
+	{
+		MBean: "system_views.thread_pools",
+		Attributes: []Attribute{
+			{MBeanAttribute: "active_tasks", Alias: "activeTasks", MetricType: metric.GAUGE},
+			{MBeanAttribute: "pending_tasks", Alias: "pendingTasks", MetricType: metric.GAUGE},
+			{MBeanAttribute: "completed_tasks", Alias: "completedTasks", MetricType: metric.GAUGE},
+			{MBeanAttribute: "blocked_tasks", Alias: "currentlyBlockedTasks", MetricType: metric.GAUGE},
+			{MBeanAttribute: "blocked_tasks_all_time", Alias: "totalBlockedTasks", MetricType: metric.GAUGE},
+			{MBeanAttribute: "active_tasks_limit", Alias: "maxPoolSize", MetricType: metric.GAUGE},
+		},
+	},
+}
+
+// clientDefinitions are the CassandraClientSample metrics, aggregated from the clients table for the connections
+// of the same user and driver.
+var clientDefinitions = []Query{
+	{
+		MBean: "system_views.clients",
+		Attributes: []Attribute{
+			{MBeanAttribute: "connections", Alias: "client.connections", MetricType: metric.GAUGE},
+			{MBeanAttribute: "ssl_connections", Alias: "client.sslConnections", MetricType: metric.GAUGE},
+			{MBeanAttribute: "request_count", Alias: "client.requestsPerSecond", MetricType: metric.RATE},
+		},
+	},
+}
+
+var clientSampleAttributes = []SampleAttribute{
+	{Key: "username", Alias: "client.username", MetricType: metric.ATTRIBUTE},
+	{Key: "driver_name", Alias: "client.driverName", MetricType: metric.ATTRIBUTE},
+	{Key: "driver_version", Alias: "client.driverVersion", MetricType: metric.ATTRIBUTE},
+	{Key: "protocol_version", Alias: "client.protocolVersion", MetricType: metric.ATTRIBUTE},
+}
+
+// sstableTaskDefinitions are the CassandraSSTableTaskSample metrics, one per compaction, cleanup, scrub or other
+// running task of the sstable_tasks table.
+var sstableTaskDefinitions = []Query{
+	{
+		MBean: "system_views.sstable_tasks",
+		Attributes: []Attribute{
+			{MBeanAttribute: "progress", Alias: "task.progress", MetricType: metric.GAUGE},
+			{MBeanAttribute: "total", Alias: "task.total", MetricType: metric.GAUGE},
+		},
+	},
+}
+
+var sstableTaskSampleAttributes = []SampleAttribute{
+	{Key: "keyspace_name", Alias: "db.keyspace", MetricType: metric.ATTRIBUTE},
+	{Key: "table_name", Alias: "db.columnFamily", MetricType: metric.ATTRIBUTE},
+	{Key: "task_id", Alias: "task.id", MetricType: metric.ATTRIBUTE},
+	{Key: "kind", Alias: "task.kind", MetricType: metric.ATTRIBUTE},
+	{Key: "unit", Alias: "task.unit", MetricType: metric.ATTRIBUTE},
+}
+
+// runSystemViewsCollection performs the metrics collection with the cql transport.
+func runSystemViewsCollection(i *integration.Integration) error {
+	definitions := NewSystemViewsDefinitions()
+	config, err := loadMetricsFilter(definitions)
+	if err != nil {
+		return err
+	}
+	definitions.Filter(config)
+
+	// The connection is opened by the first query, and again by the next collection after a failure.
+	cqlClient := &lazyCQLSource{open: openCQLSource}
+	defer func() {
+		if err := cqlClient.Close(); err != nil {
+			log.Error("Failed to close CQL connection: %s", err)
+		}
+	}()
+
+	if !args.LongRunning {
+		return collectSystemViewsMetrics(i, cqlClient, definitions)
+	}
+
+	metricInterval := time.NewTicker(time.Duration(args.Interval) * intervalUnit)
+	defer metricInterval.Stop()
+	logEvents := openLogEvents()
+	defer logEvents.Close()
+	stopHeartBeat := runHeartBeat()
+	defer stopHeartBeat()
+
+	for ; true; <-metricInterval.C {
+		logEvents.collect(i)
+		if err := collectSystemViewsMetrics(i, cqlClient, definitions); err != nil {
+			log.Error("Failed to collect metrics, error: %v", err)
+			if args.NodeStatus || logEvents != nil {
+				if err := publishFailedCollection(i); err != nil {
+					log.Error("Failed to publish node status and log events, error: %v", err)
+				}
+			}
+			continue
+		}
+
+		if err := i.Publish(); err != nil {
+			log.Error("Failed to publish metrics, error: %v", err)
+			continue
+		}
+	}
+	return nil
+}
+
+// collectSystemViewsMetrics reads the system_views tables and reports the samples. The tables not available in the
+// version of the node are skipped.
+func collectSystemViewsMetrics(i *integration.Integration, cqlClient CQLSource, definitions Definitions) error {
+	e, err := entity(i)
+	if err != nil {
+		return fmt.Errorf("failed to create entity: %w", err)
+	}
+
+	if args.NodeStatus {
+		collectNodeStatus(e, nil)
+	}
+
+	reader := &systemViewsReader{client: cqlClient, rows: make(map[string][]map[string]interface{})}
+
+	commonMetrics, err := reader.nodeMetrics(definitions.Common)
+	if err != nil {
+		return err
+	}
+	rawMetrics, err := reader.nodeMetrics(definitions.Metrics)
+	if err != nil {
+		return err
+	}
+
+	ms := metricSet(e, cassandraSample, args.Hostname, args.Port, args.RemoteMonitoring)
+	populateMetrics(ms, commonMetrics, definitions.Common)
+	populateMetrics(ms, rawMetrics, definitions.Metrics)
+
+	if args.ColumnFamiliesLimit > 0 {
+		allColumnFamilies, err := reader.columnFamilyMetrics(definitions.ColumnFamilyMetrics)
+		if err != nil {
+			return err
+		}
+
+		limiter := newColumnFamilyLimiter(args.ColumnFamiliesLimit)
+		for _, key := range sortedKeys(allColumnFamilies) {
+			columnFamilyMetrics := allColumnFamilies[key]
+			keyspace, columnFamily := columnFamilyMetrics["keyspace"].(string), columnFamilyMetrics["columnFamily"].(string)
+			if limiter.exclusion(keyspace, columnFamily) != "" {
+				continue
+			}
+
+			queries := definitions.ColumnFamilyMetrics
+			if definitions.columnFamilyFilter != nil {
+				queries = filterTableQueries(queries, *definitions.columnFamilyFilter, keyspace, columnFamily)
+			}
+
+			s := metricSet(e, columnFamilySample, args.Hostname, args.Port, args.RemoteMonitoring)
+			populateMetrics(s, commonMetrics, definitions.Common)
+			populateMetrics(s, columnFamilyMetrics, queries)
+			populateAttributes(s, columnFamilyMetrics, columnFamiliesSampleAttributes)
+		}
+	}
+
+	if args.ThreadPoolMetrics {
+		allThreadPools, err := reader.groupedMetrics(definitions.ThreadPoolMetrics, "name")
+		if err != nil {
+			return err
+		}
+
+		for _, key := range sortedKeys(allThreadPools) {
+			threadPoolMetrics := allThreadPools[key]
+			threadPoolMetrics["pool"] = key
+
+			s := metricSet(e, threadPoolSample, args.Hostname, args.Port, args.RemoteMonitoring)
+			populateMetrics(s, commonMetrics, definitions.Common)
+			populateMetrics(s, threadPoolMetrics, definitions.ThreadPoolMetrics)
+			populateAttributes(s, threadPoolMetrics, threadPoolSampleAttributes)
+		}
+	}
+
+	if args.HintsMetrics {
+		rows, err := reader.read(pendingHintsTable)
+		if err != nil {
+			return err
+		}
+
+		allHints := make(map[string]map[string]interface{})
+		addPendingHints(allHints, rows)
+		for _, key := range sortedKeys(allHints) {
+			s := metricSet(e, hintsSample, args.Hostname, args.Port, args.RemoteMonitoring)
+			populateMetrics(s, commonMetrics, definitions.Common)
+			populateAttributes(s, allHints[key], hintsSampleAttributes)
+		}
+	}
+
+	if args.ClientMetrics {
+		allClients, err := reader.clientMetrics()
+		if err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(allClients) {
+			s := metricSet(e, clientSample, args.Hostname, args.Port, args.RemoteMonitoring)
+			populateMetrics(s, allClients[key], clientDefinitions)
+			populateAttributes(s, allClients[key], clientSampleAttributes)
+		}
+	}
+
+	if args.SstableTaskMetrics {
+		allTasks, err := reader.groupedMetrics(sstableTaskDefinitions, "task_id")
+		if err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(allTasks) {
+			s := metricSet(e, sstableTaskSample, args.Hostname, args.Port, args.RemoteMonitoring)
+			populateMetrics(s, allTasks[key], sstableTaskDefinitions)
+			populateAttributes(s, allTasks[key], sstableTaskSampleAttributes)
+		}
+	}
+
+	return nil
+}
+
+// filterTableQueries returns the queries with the attributes not filtered for the table.
+func filterTableQueries(queries []Query, config FilteringConfig, keyspace, columnFamily string) []Query {
+	var result []Query
+	for _, query := range queries {
+		scope := filterScope{Sample: columnFamilySample, MBean: query.MBean, Keyspace: keyspace, Table: columnFamily}
+		query.Attributes = filterAttributes(query.Attributes, config, scope)
+		result = append(result, query)
+	}
+	return result
+}
+
+// systemViewsReader reads the rows of the tables of the queries, each table is read once per collection.
+type systemViewsReader struct {
+	client CQLSource
+	rows   map[string][]map[string]interface{}
+}
+
+// read returns the rows of the table. The tables that don't exist in the version of the node have no rows.
+func (r *systemViewsReader) read(table string) ([]map[string]interface{}, error) {
+	if rows, found := r.rows[table]; found {
+		return rows, nil
+	}
+
+	rows, err := r.client.Query("SELECT * FROM " + table)
+	if err != nil {
+		var cqlErr *CQLError
+		if !errors.As(err, &cqlErr) {
+			return nil, err
+		}
+		log.Debug("Failed to read %s: %v", table, cqlErr)
+	}
+	r.rows[table] = rows
+	return rows, nil
+}
+
+// nodeMetrics returns the metrics of the tables with a single row, or a row per name, keyed as the JMX metrics.
+func (r *systemViewsReader) nodeMetrics(queries []Query) (map[string]interface{}, error) {
+	metrics := make(map[string]interface{})
+	for _, query := range queries {
+		table, name, _ := strings.Cut(query.MBean, ",name=")
+		rows, err := r.read(table)
+		if err != nil {
+			return nil, err
+		}
+
+		var matching []map[string]interface{}
+		for _, row := range rows {
+			if name == "" || row["name"] == name {
+				matching = append(matching, row)
+			}
+		}
+
+		for _, attr := range query.Attributes {
+			key := fmt.Sprintf("%s,attr=%s", query.MBean, attr.MBeanAttribute)
+			if attr.MBeanAttribute == systemViewsRowCount {
+				metrics[key] = len(matching)
+				continue
+			}
+			if len(matching) > 0 && matching[0][attr.MBeanAttribute] != nil {
+				metrics[key] = matching[0][attr.MBeanAttribute]
+			}
+		}
+	}
+	return metrics, nil
+}
+
+// groupedMetrics returns the metrics of the tables with a row per entity, grouped by the value of the column.
+// The columns of the rows are kept for the sample attributes.
+func (r *systemViewsReader) groupedMetrics(queries []Query, column string) (map[string]map[string]interface{}, error) {
+	result := make(map[string]map[string]interface{})
+	for _, query := range queries {
+		rows, err := r.read(query.MBean)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			key := fmt.Sprint(row[column])
+			metrics, found := result[key]
+			if !found {
+				metrics = make(map[string]interface{})
+				result[key] = metrics
+			}
+			setRowMetrics(metrics, query, row)
+		}
+	}
+	return result, nil
+}
+
+// columnFamilyMetrics returns the metrics of the tables with a row per keyspace and table, keyed by
+// '<keyspace>.<table>', with the attributes of the CassandraColumnFamilySample.
+func (r *systemViewsReader) columnFamilyMetrics(queries []Query) (map[string]map[string]interface{}, error) {
+	result := make(map[string]map[string]interface{})
+	for _, query := range queries {
+		rows, err := r.read(query.MBean)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			keyspace, ok := row["keyspace_name"].(string)
+			if !ok {
+				continue
+			}
+			columnFamily, ok := row["table_name"].(string)
+			if !ok {
+				continue
+			}
+
+			key := keyspace + "." + columnFamily
+			metrics, found := result[key]
+			if !found {
+				metrics = map[string]interface{}{
+					"keyspace":                keyspace,
+					"columnFamily":            columnFamily,
+					"keyspaceAndColumnFamily": key,
+				}
+				result[key] = metrics
+			}
+			setRowMetrics(metrics, query, row)
+		}
+	}
+	return result, nil
+}
+
+// clientMetrics aggregates the connections of the clients table by user, driver and protocol version.
+func (r *systemViewsReader) clientMetrics() (map[string]map[string]interface{}, error) {
+	rows, err := r.read("system_views.clients")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string]interface{})
+	for _, row := range rows {
+		var keys []string
+		for _, attr := range clientSampleAttributes {
+			keys = append(keys, fmt.Sprint(row[attr.Key]))
+		}
+		key := strings.Join(keys, "|")
+
+		metrics, found := result[key]
+		if !found {
+			metrics = map[string]interface{}{}
+			for _, attr := range clientSampleAttributes {
+				if row[attr.Key] != nil {
+					metrics[attr.Key] = fmt.Sprint(row[attr.Key])
+				}
+			}
+			result[key] = metrics
+		}
+
+		addClientMetric(metrics, "connections", int64(1))
+		if sslEnabled, _ := row["ssl_enabled"].(bool); sslEnabled {
+			addClientMetric(metrics, "ssl_connections", int64(1))
+		} else {
+			addClientMetric(metrics, "ssl_connections", int64(0))
+		}
+		if requests, ok := row["request_count"].(int64); ok {
+			addClientMetric(metrics, "request_count", requests)
+		}
+	}
+	return result, nil
+}
+
+func addClientMetric(metrics map[string]interface{}, column string, value int64) {
+	key := fmt.Sprintf("system_views.clients,attr=%s", column)
+	previous, _ := metrics[key].(int64)
+	metrics[key] = previous + value
+}
+
+// setRowMetrics sets the columns of the row read by the query, keyed as the JMX metrics, and the rest of the
+// columns by their name.
+func setRowMetrics(metrics map[string]interface{}, query Query, row map[string]interface{}) {
+	for column, value := range row {
+		if value != nil {
+			metrics[column] = fmt.Sprint(value)
+		}
+	}
+	for _, attr := range query.Attributes {
+		if value := row[attr.MBeanAttribute]; value != nil {
+			metrics[fmt.Sprintf("%s,attr=%s", query.MBean, attr.MBeanAttribute)] = value
+		}
+	}
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2022 New Relic Corporation. All rights reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cqlText(value string) []byte {
+	return []byte(value)
+}
+
+func cqlBigint(value int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(value))
+}
+
+func cqlDouble(value float64) []byte {
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(value))
+}
+
+func cqlColumns(types map[string]uint16, names ...string) []fakeCQLColumn {
+	var columns []fakeCQLColumn
+	for _, name := range names {
+		columns = append(columns, fakeCQLColumn{name: name, option: cqlOption(types[name])})
+	}
+	return columns
+}
+
+// systemViewsResults are the system_views tables of a Cassandra 4.0 node, the ones missing are answered with an
+// error like the tables added in later versions.
+func systemViewsResults() map[string]fakeCQLResult {
+	text, bigint, double, boolean := uint16(cqlTypeVarchar), uint16(cqlTypeBigint), uint16(cqlTypeDouble), uint16(cqlTypeBoolean)
+	types := map[string]uint16{
+		"release_version": text, "cluster_name": text, "data_center": text, "rack": text,
+		"name": text, "capacity_bytes": bigint, "entry_count": bigint, "hit_count": bigint, "hit_ratio": double,
+		"request_count": bigint, "size_bytes": bigint,
+		"username": text, "driver_name": text, "driver_version": text, "protocol_version": bigint, "ssl_enabled": boolean,
+		"keyspace_name": text, "table_name": text, "mebibytes": bigint,
+		"count": bigint, "p50th_ms": double, "p99th_ms": double, "max_ms": double,
+		"active_tasks": bigint, "pending_tasks": bigint, "completed_tasks": bigint, "blocked_tasks": bigint,
+		"blocked_tasks_all_time": bigint, "active_tasks_limit": bigint,
+		"task_id": uint16(cqlTypeTimeUUID), "kind": text, "progress": bigint, "total": bigint, "unit": text,
+	}
+
+	return map[string]fakeCQLResult{
+		"SELECT * FROM system.local": {
+			columns: cqlColumns(types, "release_version", "cluster_name", "data_center", "rack"),
+			rows:    [][][]byte{{cqlText("4.0.3"), cqlText("Test Cluster"), cqlText("datacenter1"), cqlText("rack1")}},
+		},
+		"SELECT * FROM system_views.caches": {
+			columns: cqlColumns(types, "name", "capacity_bytes", "entry_count", "hit_count", "hit_ratio", "request_count", "size_bytes"),
+			rows: [][][]byte{
+				{cqlText("keys"), cqlBigint(104857600), cqlBigint(1200), cqlBigint(900), cqlDouble(0.75), cqlBigint(1200), cqlBigint(52428800)},
+				{cqlText("rows"), cqlBigint(0), cqlBigint(0), cqlBigint(0), cqlDouble(math.NaN()), cqlBigint(0), cqlBigint(0)},
+			},
+		},
+		"SELECT * FROM system_views.clients": {
+			columns: cqlColumns(types, "username", "driver_name", "driver_version", "protocol_version", "request_count", "ssl_enabled"),
+			rows: [][][]byte{
+				{cqlText("app"), cqlText("DataStax Java driver"), cqlText("4.13.0"), cqlBigint(5), cqlBigint(100), {1}},
+				{cqlText("app"), cqlText("DataStax Java driver"), cqlText("4.13.0"), cqlBigint(5), cqlBigint(50), {0}},
+				{cqlText("admin"), nil, nil, cqlBigint(5), cqlBigint(3), {0}},
+			},
+		},
+		"SELECT * FROM system_views.disk_usage": {
+			columns: cqlColumns(types, "keyspace_name", "table_name", "mebibytes"),
+			rows: [][][]byte{
+				{cqlText("users"), cqlText("profiles"), cqlBigint(2)},
+				{cqlText("system"), cqlText("local"), cqlBigint(1)},
+			},
+		},
+		"SELECT * FROM system_views.local_read_latency": {
+			columns: cqlColumns(types, "keyspace_name", "table_name", "count", "p50th_ms", "p99th_ms", "max_ms"),
+			rows: [][][]byte{
+				{cqlText("users"), cqlText("profiles"), cqlBigint(250), cqlDouble(0.5), cqlDouble(4.2), cqlDouble(12.0)},
+			},
+		},
+		"SELECT * FROM system_views.thread_pools": {
+			columns: cqlColumns(types, "name", "active_tasks", "pending_tasks", "completed_tasks", "blocked_tasks", "blocked_tasks_all_time", "active_tasks_limit"),
+			rows: [][][]byte{
+				{cqlText("ReadStage"), cqlBigint(2), cqlBigint(5), cqlBigint(1000), cqlBigint(0), cqlBigint(0), cqlBigint(32)},
+			},
+		},
+		"SELECT * FROM system_views.sstable_tasks": {
+			columns: cqlColumns(types, "keyspace_name", "table_name", "task_id", "kind", "progress", "total", "unit"),
+			rows: [][][]byte{
+				{
+					cqlText("users"), cqlText("profiles"),
+					{0x8a, 0x1d, 0x2c, 0x00, 0x1e, 0x5b, 0x11, 0xed, 0x9b, 0x8a, 0x0f, 0x9e, 0x1d, 0x6e, 0x3c, 0x01},
+					cqlText("compaction"), cqlBigint(1048576), cqlBigint(4194304), cqlText("bytes"),
+				},
+			},
+		},
+	}
+}
+
+func setupSystemViewsCollection(t *testing.T) *fakeCQLServer {
+	t.Helper()
+
+	server := newFakeCQLServer(t, "", "", systemViewsResults())
+
+	args.Transport = transportCQL
+	args.CQLHostname, args.CQLPort = splitHostPort(t, server.listener.Addr().String())
+	args.Timeout = 1000
+	return server
+}
+
+func splitHostPort(t *testing.T, address string) (string, int) {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(address)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return host, portNumber
+}
+
+func TestRunSystemViewsCollection(t *testing.T) {
+	i := setupCollection(t)
+	setupSystemViewsCollection(t)
+	args.ThreadPoolMetrics = true
+	args.ClientMetrics = true
+	args.SstableTaskMetrics = true
+
+	require.NoError(t, runSystemViewsCollection(i))
+
+	samples := samplesByEventType(i)
+	require.Len(t, samples[cassandraSample], 1)
+	sample := samples[cassandraSample][0]
+	assert.Equal(t, "4.0.3", sample["software.version"])
+	assert.Equal(t, "datacenter1", sample["cluster.datacenter"])
+	assert.Equal(t, 3.0, sample["client.connectedNativeClients"])
+	assert.Equal(t, 104857600.0, sample["db.keyCacheCapacityBytes"])
+	assert.Equal(t, 0.75, sample["db.keyCacheHitRate"])
+	assert.Equal(t, 1200.0, sample["db.keyCacheEntries"])
+	assert.Equal(t, 0.0, sample["db.rowCacheSizeBytes"])
+	assert.NotContains(t, sample, "db.counterCacheSizeBytes")
+
+	require.Len(t, samples[columnFamilySample], 1)
+	columnFamily := samples[columnFamilySample][0]
+	assert.Equal(t, "users.profiles", columnFamily["db.keyspaceAndColumnFamily"])
+	assert.Equal(t, "4.0.3", columnFamily["software.version"])
+	assert.Equal(t, 2097152.0, columnFamily["db.liveDiskSpaceUsedBytes"])
+	assert.Equal(t, 4.2, columnFamily["query.readLatency99thPercentileMilliseconds"])
+	assert.Equal(t, 12.0, columnFamily["query.readLatencyMaxMilliseconds"])
+	assert.NotContains(t, columnFamily, "db.maxRowSize")
+
+	require.Len(t, samples[threadPoolSample], 1)
+	threadPool := samples[threadPoolSample][0]
+	assert.Equal(t, "ReadStage", threadPool["pool"])
+	assert.Equal(t, 5.0, threadPool["pendingTasks"])
+	assert.Equal(t, 32.0, threadPool["maxPoolSize"])
+
+	require.Len(t, samples[clientSample], 2)
+	admin, app := samples[clientSample][0], samples[clientSample][1]
+	assert.Equal(t, "admin", admin["client.username"])
+	assert.NotContains(t, admin, "client.driverName")
+	assert.Equal(t, 1.0, admin["client.connections"])
+	assert.Equal(t, "app", app["client.username"])
+	assert.Equal(t, "DataStax Java driver", app["client.driverName"])
+	assert.Equal(t, "5", app["client.protocolVersion"])
+	assert.Equal(t, 2.0, app["client.connections"])
+	assert.Equal(t, 1.0, app["client.sslConnections"])
+
+	require.Len(t, samples[sstableTaskSample], 1)
+	task := samples[sstableTaskSample][0]
+	assert.Equal(t, "users", task["db.keyspace"])
+	assert.Equal(t, "compaction", task["task.kind"])
+	assert.Equal(t, "8a1d2c00-1e5b-11ed-9b8a-0f9e1d6e3c01", task["task.id"])
+	assert.Equal(t, 1048576.0, task["task.progress"])
+	assert.Equal(t, 4194304.0, task["task.total"])
+}
+
+func TestRunSystemViewsCollection_Filter(t *testing.T) {
+	i := setupCollection(t)
+	setupSystemViewsCollection(t)
+	args.MetricsFilter = `
+exclude:
+  - metric: "db.keyCache*"
+  - keyspace: users
+    table: profiles
+    metric: "query.*"
+`
+
+	require.NoError(t, runSystemViewsCollection(i))
+
+	samples := samplesByEventType(i)
+	assert.NotContains(t, samples[cassandraSample][0], "db.keyCacheHitRate")
+	assert.Contains(t, samples[cassandraSample][0], "db.rowCacheSizeBytes")
+	assert.NotContains(t, samples[columnFamilySample][0], "query.readLatencyMaxMilliseconds")
+	assert.Contains(t, samples[columnFamilySample][0], "db.liveDiskSpaceUsedBytes")
+	assert.NotContains(t, samples, clientSample, "the client samples are opt-in")
+	assert.NotContains(t, samples, sstableTaskSample, "the SSTable task samples are opt-in")
+}
+
+func TestRunSystemViewsCollection_StrictMetricsFilter(t *testing.T) {
+	i := setupCollection(t)
+	setupSystemViewsCollection(t)
+	// The load of the node is only reported through JMX.
+	args.MetricsFilter = `
+exclude:
+  - db.loadBytes
+`
+	args.MetricsFilterStrict = true
+
+	assert.ErrorIs(t, runSystemViewsCollection(i), errInvalidMetricsFilter)
+}
+
+func TestRunSystemViewsCollection_ConnectionError(t *testing.T) {
+	i := setupCollection(t)
+	server := setupSystemViewsCollection(t)
+	require.NoError(t, server.listener.Close())
+
+	assert.ErrorContains(t, runSystemViewsCollection(i), "failed to connect to CQL endpoint")
+}
@@ -0,0 +1,25 @@
+calculate_heap_sizes()
+{
+    system_memory_in_mb=`free -m | awk '/:/ {print $2;exit}'`
+}
+
+#MAX_HEAP_SIZE="4G"
+#HEAP_NEWSIZE="800M"
+
+# Specifies the default port over which Cassandra will be available for
+# JMX connections.
+JMX_PORT="7199"
+
+if [ "x$LOCAL_JMX" = "x" ]; then
+    LOCAL_JMX=yes
+fi
+
+if [ "$LOCAL_JMX" = "yes" ]; then
+  JVM_OPTS="$JVM_OPTS -Dcassandra.jmx.local.port=$JMX_PORT"
+  JVM_OPTS="$JVM_OPTS -Dcom.sun.management.jmxremote.authenticate=false"
+else
+  JVM_OPTS="$JVM_OPTS -Dcom.sun.management.jmxremote.authenticate=true"
+fi
+
+JVM_OPTS="$JVM_OPTS -Djava.rmi.server.hostname=10.0.0.1 -XX:+UseCondCardMark"
+export JMX_PASSWORD='s3cr3t' # Used by nodetool.